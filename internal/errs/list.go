@@ -3,7 +3,6 @@ package errs
 import "errors"
 
 var (
-	ErrNoAccess              = errors.New("no access")
 	ErrInvalidTimeFormat     = errors.New("invalid time format")
 	ErrInvalidTitle          = errors.New("title must be at least 3 characters long")
 	ErrInvalidDescription    = errors.New("description must be at least 10 characters long")
@@ -11,12 +10,57 @@ var (
 	ErrEmptyEndTime          = errors.New("end time is required")
 	ErrInvalidLotID          = errors.New("invalid lot ID")
 	ErrFoundLot              = errors.New("lot not found")
-	ErrAdminAccessDenied     = errors.New("admin access required")
+	ErrRoleNotFound          = errors.New("role not found")
+	ErrCannotChangeOwnRole   = errors.New("cannot change own role")
 	ErrBidTooLow             = errors.New("bid too low")
 	ErrCannotBidOnOwnLot     = errors.New("cannot bid on own lot")
 	ErrInvalidUsername       = errors.New("username must be at least 3 characters")
 	ErrInvalidPassword       = errors.New("password must be at least 8 characters")
-	ErrAlreadyExists         = errors.New("already exists")
 	ErrUsernameAlreadyExists = errors.New("username already exists")
 	ErrEmailAlreadyExists    = errors.New("email already exists")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrTooManyAttempts       = errors.New("too many failed login attempts")
+	ErrInvalidToken          = errors.New("invalid token")
+	ErrInvalidAPIKey         = errors.New("invalid api key")
+	ErrAPIKeyNotFound        = errors.New("api key not found")
+	ErrInvalidAPIKeyName     = errors.New("api key name is required")
+	ErrInvalidScope          = errors.New("scope is unknown or not granted to the user")
+	ErrInvalidExpiry         = errors.New("expiry must be in the future and at most a year away")
+	ErrEmailNotVerified      = errors.New("email not verified")
+	ErrEmailAlreadyVerified  = errors.New("email already verified")
+	ErrRefreshTokenNotFound  = errors.New("refresh token not found")
+	ErrRefreshTokenReused    = errors.New("refresh token already used")
+	ErrResetTokenInvalid     = errors.New("reset token is invalid or expired")
+	ErrMFAAlreadyEnabled     = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled         = errors.New("two-factor authentication not enabled")
+	ErrMFACodeInvalid        = errors.New("invalid two-factor code")
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrOIDCStateInvalid      = errors.New("login state is invalid or expired")
+	ErrIdentityNotFound      = errors.New("identity not linked")
+	ErrOIDCEmailMissing      = errors.New("identity provider did not return an email")
+	ErrInvalidStartTime      = errors.New("start time must be before end time")
+	ErrLotNotStarted         = errors.New("auction has not started yet")
+	ErrLotClosed             = errors.New("auction is closed")
+	ErrLotCancelled          = errors.New("auction is cancelled")
+	ErrNotLotOwner           = errors.New("only the seller can manage this lot")
+	ErrLotHasBids            = errors.New("lot already has bids")
+	ErrReserveNotMet         = errors.New("reserve price not met")
+	ErrReasonTooLong         = errors.New("reason must be at most 255 characters")
+	ErrNothingToUpdate       = errors.New("no fields to update")
+	ErrLotEditRestricted     = errors.New("only appending to the description is allowed after bidding starts")
+	ErrBidConflict           = errors.New("bid conflict: outbid by a concurrent bid")
+	ErrInvalidIncrements     = errors.New("invalid increment schedule")
+	ErrInvalidReservePrice   = errors.New("reserve price must be greater than start price")
+	ErrInvalidBuyNowPrice    = errors.New("buy now price must be greater than start and reserve price")
+	ErrBuyNowUnavailable     = errors.New("buy now is not available for this lot")
+	ErrInvalidAuctionType    = errors.New("invalid auction type")
+	ErrInvalidDutchLot       = errors.New("dutch lot needs floor price below start price, decrement and interval")
+	ErrNotDutchAuction       = errors.New("lot is not a dutch auction")
+	ErrDutchAuctionBid       = errors.New("dutch auction lots are bought by accepting the current price")
+	ErrProxyBidNotAllowed    = errors.New("max amount is not allowed on sealed-bid lots")
+	ErrInvalidMaxAmount      = errors.New("max amount must not be less than amount")
+	ErrProxyBidNotFound      = errors.New("proxy bid not found")
+	ErrNoBids                = errors.New("no bids")
+	ErrWinnerNotFound        = errors.New("winner not found")
 )
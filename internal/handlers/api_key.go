@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"auction/internal/errs"
+	"auction/internal/middleware"
+	"auction/internal/models"
+	"auction/internal/service"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// @Summary Создание API-ключа
+// @Description Создает API-ключ с указанными разрешениями. Ключ возвращается только один раз;
+// @Description передавайте его в заголовке X-API-Key. Без expires_at ключ действует 90 дней;
+// @Description срок действия не может превышать один год
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CreateAPIKeyRequest true "Название, разрешения и срок действия"
+// @Success 201 {object} models.CreateAPIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/api-keys/create [post]
+func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.apiKeyService.CreateAPIKey(r.Context(), user.ID, req)
+	if err != nil {
+		switch err {
+		case errs.ErrInvalidAPIKeyName, errs.ErrInvalidScope, errs.ErrInvalidExpiry:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("error creating api key: %v", err)
+			http.Error(w, "error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Список API-ключей
+// @Description Возвращает API-ключи пользователя без секретной части
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := h.apiKeyService.GetAPIKeys(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("error getting api keys: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// @Summary Отзыв API-ключа
+// @Description Отзывает API-ключ пользователя
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param id query int true "ID ключа" minimum(1)
+// @Success 204 "Ключ отозван"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/api-keys/revoke [post]
+func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		http.Error(w, "invalid api key ID", http.StatusBadRequest)
+		return
+	}
+
+	err = h.apiKeyService.RevokeAPIKey(r.Context(), user.ID, id)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errs.ErrAPIKeyNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("error revoking api key: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
+}
@@ -1,23 +1,25 @@
 package handlers
 
 import (
+	"auction/internal/errs"
+	"auction/internal/middleware"
 	"auction/internal/models"
-	"auction/internal/pkg"
-	"auction/internal/utils"
-	"database/sql"
+	"auction/internal/service"
 	"encoding/json"
-	"errors"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 )
 
 type AuthHandler struct {
-	db *sql.DB
+	authService *service.AuthService
 }
 
-func NewAuthHandler(db *sql.DB) *AuthHandler {
+func NewAuthHandler(authService *service.AuthService) *AuthHandler {
 	return &AuthHandler{
-		db: db,
+		authService: authService,
 	}
 }
 
@@ -40,49 +42,22 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var existingEmail string
-	err := h.db.QueryRow("SELECT email FROM users WHERE email = $1", req.Email).Scan(&existingEmail)
-	if err != sql.ErrNoRows {
-		http.Error(w, "email already exists", http.StatusConflict)
-		return
-	}
-
-	hashedPassword, err := utils.HashPassword(req.Password)
-	if err != nil {
-		http.Error(w, "failed to process password", http.StatusInternalServerError)
-		return
-	}
-
-	var userID int
-	err = h.db.QueryRow("INSERT INTO users(username, email, password_hash, role) VALUES ($1,$2,$3,$4) RETURNING id",
-		req.Username,
-		req.Email,
-		hashedPassword,
-		"user").Scan(&userID)
-
-	if err != nil {
-		log.Printf("database INSERT error: %v", err)
-		http.Error(w, "error", http.StatusInternalServerError)
-		return
-	}
-
-	user := models.User{
-		ID:       userID,
-		Username: req.Username,
-		Email:    req.Email,
-		Role:     "user",
-	}
-
-	accessToken, refreshToken, err := h.generateTokenPair(user)
+	response, err := h.authService.Register(r.Context(), req)
 	if err != nil {
-		http.Error(w, "error generating token", http.StatusInternalServerError)
+		switch err {
+		case errs.ErrInvalidUsername, errs.ErrInvalidPassword:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errs.ErrEmailAlreadyExists:
+			http.Error(w, "email already exists", http.StatusConflict)
+		case errs.ErrUsernameAlreadyExists:
+			http.Error(w, "username already exists", http.StatusConflict)
+		default:
+			log.Printf("error registering user: %v", err)
+			http.Error(w, "error", http.StatusInternalServerError)
+		}
 		return
 	}
 
-	response := models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -93,14 +68,17 @@ type LoginRequest struct {
 }
 
 // @Summary Авторизация пользователя
-// @Description Авторизует пользователя и возвращает токены
+// @Description Авторизует пользователя и возвращает токены. Если включена двухфакторная
+// @Description аутентификация, вместо токенов возвращается MFA-токен для /api/login/mfa
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param request body models.SignInRequest true "Данные для авторизации"
 // @Success 200 {object} models.AuthResponse
+// @Success 202 {object} models.MFAChallengeResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/login [post]
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
@@ -113,92 +91,281 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var user models.User
-	var hashedPassword string
-	err := h.db.QueryRow(
-		"SELECT id, username, email, password_hash, role FROM users WHERE username = $1",
-		req.Username,
-	).Scan(&user.ID,
-		&user.Username,
-		&user.Email,
-		&hashedPassword,
-		&user.Role)
+	result, err := h.authService.Login(r.Context(), req.Username, req.Password, clientIP(r))
+	if err != nil {
+		writeLoginError(w, result, err)
+		return
+	}
+	writeLoginResult(w, result)
+}
 
-	if !utils.CheckPasswordHash(req.Password, hashedPassword) {
-		log.Printf("password mismatch for user: %v", err)
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+// @Summary Обновление токенов
+// @Description Обменивает refresh-токен на новую пару токенов. Каждый refresh-токен одноразовый;
+// @Description повторное использование отзывает всю цепочку токенов
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.RefreshRequest true "Refresh-токен"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/refresh [post]
+func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	accessToken, refreshToken, err := h.generateTokenPair(user)
+	response, err := h.authService.Refresh(r.Context(), req.RefreshToken)
+	if err == errs.ErrInvalidToken {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		http.Error(w, "error generating token", http.StatusInternalServerError)
+		log.Printf("error refreshing tokens: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
 		return
 	}
 
-	response := models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (h *AuthHandler) RefreshToken(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		RefreshToken string `json:"refresh_token" validate:"required"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+// @Summary Выход из системы
+// @Description Отзывает текущий access-токен и, если передан, цепочку refresh-токена
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.RefreshRequest false "Refresh-токен текущей сессии"
+// @Success 204 "Сессия завершена"
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaimsFromContext(r.Context())
+	if claims == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	claims, err := pkg.ValidateToken(req.RefreshToken)
-	if err != nil {
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+	// The body is optional: without a refresh token only the access token is revoked.
+	var req models.RefreshRequest
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if err := h.authService.Logout(r.Context(), claims, req.RefreshToken); err != nil {
+		log.Printf("error logging out: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
 		return
 	}
-	if claims.TokenType != "refresh" {
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Отзыв всех сессий пользователя
+// @Description Отзывает все выданные пользователю токены и API-ключи (только для администраторов)
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id query int true "ID пользователя" minimum(1)
+// @Success 204 "Сессии отозваны"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/admin/users/revoke-sessions [post]
+func (h *AuthHandler) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		http.Error(w, "invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	var user models.User
-	err = h.db.QueryRow("SELECT id, username, email, role FROM users WHERE id = $1").Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.Role)
+	if err := h.authService.RevokeAllSessions(r.Context(), id); err != nil {
+		log.Printf("error revoking sessions for user %d: %v", id, err)
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	if err != nil {
-		log.Printf("database INSERT error: %v", err)
+// @Summary Подтверждение email
+// @Description Подтверждает email по одноразовому токену из письма
+// @Tags auth
+// @Produce json
+// @Param token query string true "Токен подтверждения"
+// @Success 204 "Email подтвержден"
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/verify-email [get]
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	err := h.authService.VerifyEmail(r.Context(), r.URL.Query().Get("token"))
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errs.ErrInvalidToken:
+		http.Error(w, "invalid token", http.StatusBadRequest)
+	case errs.ErrEmailAlreadyVerified:
+		http.Error(w, "email already verified or token is no longer valid", http.StatusConflict)
+	default:
+		log.Printf("error verifying email: %v", err)
 		http.Error(w, "error", http.StatusInternalServerError)
+	}
+}
+
+// @Summary Повторная отправка письма подтверждения
+// @Description Отправляет новое письмо со ссылкой подтверждения email
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 202 "Письмо отправлено"
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	accessToken, refreshToken, err := h.generateTokenPair(user)
-	if err != nil {
-		http.Error(w, "error generating token", http.StatusInternalServerError)
+	err := h.authService.ResendVerification(r.Context(), user.ID)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusAccepted)
+	case errs.ErrEmailAlreadyVerified:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("error sending verification email to user %d: %v", user.ID, err)
+		http.Error(w, "error sending email", http.StatusInternalServerError)
+	}
+}
+
+// @Summary Запрос сброса пароля
+// @Description Отправляет на email ссылку для сброса пароля. Ответ не зависит от того, зарегистрирован ли email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Email пользователя"
+// @Success 202 "Запрос принят"
+// @Failure 400 {object} map[string]string
+// @Router /api/password/forgot [post]
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.ForgotPassword(r.Context(), req.Email); err != nil {
+		log.Printf("error sending password reset: %v", err)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// @Summary Сброс пароля
+// @Description Устанавливает новый пароль по одноразовому токену из письма и завершает все сессии пользователя
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Токен сброса и новый пароль"
+// @Success 204 "Пароль изменен"
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/password/reset [post]
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	response := models.AuthResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+	err := h.authService.ResetPassword(r.Context(), req.Token, req.Password)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errs.ErrInvalidPassword, errs.ErrResetTokenInvalid:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("error resetting password: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
 	}
+}
+
+// resetPasswordPage lets a user without a frontend use the link from the
+// password reset email: it posts the token and new password to
+// /api/password/reset.
+const resetPasswordPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Reset password</title></head>
+<body>
+<form id="reset">
+  <label>New password <input type="password" id="password" minlength="8" required></label>
+  <button type="submit">Reset password</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  const token = new URLSearchParams(location.search).get("token");
+  const resp = await fetch("/api/password/reset", {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({token: token, password: document.getElementById("password").value}),
+  });
+  document.getElementById("result").textContent =
+    resp.ok ? "Password changed. You can now log in." : await resp.text();
+});
+</script>
+</body>
+</html>
+`
+
+// ResetPasswordPage serves the page the password reset email links to when
+// PASSWORD_RESET_URL is not set.
+func (h *AuthHandler) ResetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Write([]byte(resetPasswordPage))
+}
+
+func writeLoginResult(w http.ResponseWriter, result *service.LoginResult) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	if result.Tokens == nil {
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(models.MFAChallengeResponse{MFARequired: true, MFAToken: result.MFAToken})
+		return
+	}
+	json.NewEncoder(w).Encode(result.Tokens)
 }
 
-func (h *AuthHandler) generateTokenPair(user models.User) (accessToken string, refreshToken string, err error) {
-	accessToken, err = pkg.GenerateToken(user.ID, user.Username, user.Email, user.Role, "access")
-	if err != nil {
-		return "", "", errors.New("error generating token")
+func writeLoginError(w http.ResponseWriter, result *service.LoginResult, err error) {
+	switch err {
+	case errs.ErrTooManyAttempts:
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errs.ErrInvalidCredentials:
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+	case errs.ErrInvalidToken:
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	case errs.ErrMFANotEnabled:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errs.ErrMFACodeInvalid:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		log.Printf("error logging in: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
 	}
+}
 
-	refreshToken, err = pkg.GenerateToken(user.ID, user.Username, user.Email, user.Role, "refresh")
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return "", "", errors.New("error generating token")
+		return r.RemoteAddr
 	}
-	return accessToken, refreshToken, nil
+	return host
 }
@@ -24,7 +24,8 @@ func NewBidHandler(db *sql.DB, bidService *service.BidService) *BidHandler {
 }
 
 // @Summary Создание ставки на лот
-// @Description Позволяет пользователю сделать ставку на активный лот
+// @Description Позволяет пользователю сделать ставку на активный лот. Если указан max_amount,
+// @Description система автоматически повышает ставку на минимальный шаг до этой суммы
 // @Tags bids
 // @Accept json
 // @Produce json
@@ -35,7 +36,7 @@ func NewBidHandler(db *sql.DB, bidService *service.BidService) *BidHandler {
 // @Failure 401 {object} models.ErrorResponse "Не авторизован"
 // @Failure 403 {object} models.ErrorResponse "Доступ запрещен (не пользователь)"
 // @Failure 404 {object} models.ErrorResponse "Лот не найден"
-// @Failure 409 {object} models.ErrorResponse "Нельзя делать ставку на собственный лот"
+// @Failure 409 {object} models.ErrorResponse "Аукцион не начался, завершен или отменен, либо ставку перебила одновременная ставка"
 // @Failure 422 {object} models.ErrorResponse "Ставка ниже текущей цены"
 // @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
 // @Router /auth/bids/create [post]
@@ -45,10 +46,6 @@ func (h *BidHandler) CreateBid(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if user.Role != "user" {
-		http.Error(w, "no access", http.StatusUnauthorized)
-		return
-	}
 	var bid models.PlaceBid
 	if err := json.NewDecoder(r.Body).Decode(&bid); err != nil {
 		http.Error(w, "invalid request body", http.StatusBadRequest)
@@ -61,8 +58,24 @@ func (h *BidHandler) CreateBid(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "lot not found", http.StatusNotFound)
 		case errs.ErrBidTooLow:
 			http.Error(w, "lot too low", http.StatusBadRequest)
+		case errs.ErrEmailNotVerified:
+			http.Error(w, "email not verified", http.StatusForbidden)
+		case errs.ErrInvalidMaxAmount:
+			http.Error(w, "max amount must not be less than amount", http.StatusBadRequest)
+		case errs.ErrDutchAuctionBid:
+			http.Error(w, "dutch auction lots are bought by accepting the current price", http.StatusBadRequest)
+		case errs.ErrProxyBidNotAllowed:
+			http.Error(w, "max amount is not allowed on sealed-bid lots", http.StatusBadRequest)
 		case errs.ErrCannotBidOnOwnLot:
 			http.Error(w, "cannot bid on own lot", http.StatusBadRequest)
+		case errs.ErrLotNotStarted:
+			http.Error(w, "auction has not started yet", http.StatusConflict)
+		case errs.ErrLotClosed:
+			http.Error(w, "auction is closed", http.StatusConflict)
+		case errs.ErrLotCancelled:
+			http.Error(w, "auction is cancelled", http.StatusConflict)
+		case errs.ErrBidConflict:
+			http.Error(w, "outbid by a concurrent bid", http.StatusConflict)
 		default:
 			log.Printf("error creating bid: %v", err)
 			http.Error(w, "error creating bid", http.StatusInternalServerError)
@@ -90,10 +103,6 @@ func (h *BidHandler) GetMyBids(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	if user.Role != "user" {
-		http.Error(w, "no access", http.StatusUnauthorized)
-		return
-	}
 	bids, err := h.bidService.GetMyBids(r.Context(), user.ID)
 	if err != nil {
 		log.Printf("error getting bids: %v", err)
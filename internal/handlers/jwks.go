@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"auction/internal/pkg"
+	"encoding/json"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	keys *pkg.KeyManager
+}
+
+func NewJWKSHandler(keys *pkg.KeyManager) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// @Summary Публичные ключи для проверки токенов
+// @Description Возвращает набор открытых ключей (JWKS), которыми подписаны access- и refresh-токены
+// @Tags auth
+// @Produce json
+// @Success 200 {object} pkg.JWKS
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
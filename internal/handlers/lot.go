@@ -74,8 +74,8 @@ func (h *LotHandler) CreateLot(w http.ResponseWriter, r *http.Request) {
 	lotID, err := h.lotService.CreateLot(r.Context(), user.ID, lot)
 	if err != nil {
 		switch err {
-		case errs.ErrNoAccess:
-			http.Error(w, "access denied", http.StatusUnauthorized)
+		case errs.ErrEmailNotVerified:
+			http.Error(w, "email not verified", http.StatusForbidden)
 		case errs.ErrInvalidTimeFormat:
 			http.Error(w, "invalid time format", http.StatusBadRequest)
 		case errs.ErrInvalidTitle:
@@ -84,6 +84,18 @@ func (h *LotHandler) CreateLot(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "invalid description", http.StatusBadRequest)
 		case errs.ErrInvalidPrice:
 			http.Error(w, "invalid price", http.StatusBadRequest)
+		case errs.ErrInvalidStartTime:
+			http.Error(w, "invalid start time", http.StatusBadRequest)
+		case errs.ErrInvalidReservePrice:
+			http.Error(w, "invalid reserve price", http.StatusBadRequest)
+		case errs.ErrInvalidBuyNowPrice:
+			http.Error(w, "invalid buy now price", http.StatusBadRequest)
+		case errs.ErrInvalidAuctionType:
+			http.Error(w, "invalid auction type", http.StatusBadRequest)
+		case errs.ErrInvalidDutchLot:
+			http.Error(w, "invalid dutch auction settings", http.StatusBadRequest)
+		case errs.ErrInvalidIncrements:
+			http.Error(w, "invalid increment schedule", http.StatusBadRequest)
 		default:
 			log.Printf("error creating lot: %v", err)
 			http.Error(w, "error creating lot", http.StatusInternalServerError)
@@ -98,6 +110,114 @@ func (h *LotHandler) CreateLot(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// @Summary Покупка лота по цене «Купить сейчас»
+// @Description Покупает лот по цене buy_now_price, завершает аукцион и записывает покупателя победителем
+// @Tags lots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.BuyNowRequest true "ID лота"
+// @Success 200 {object} models.Winner "Лот куплен"
+// @Failure 400 {object} models.ErrorResponse "Неверные данные запроса"
+// @Failure 401 {object} models.ErrorResponse "Не авторизован"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 409 {object} models.ErrorResponse "Покупка недоступна или аукцион завершен"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/lots/buy-now [post]
+func (h *LotHandler) BuyNow(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.BuyNowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	winner, err := h.lotService.BuyNow(r.Context(), user.ID, req.LotID)
+	if err != nil {
+		switch err {
+		case errs.ErrInvalidLotID:
+			http.Error(w, "invalid lot ID", http.StatusBadRequest)
+		case errs.ErrFoundLot:
+			http.Error(w, "lot not found", http.StatusNotFound)
+		case errs.ErrEmailNotVerified:
+			http.Error(w, "email not verified", http.StatusForbidden)
+		case errs.ErrCannotBidOnOwnLot:
+			http.Error(w, "cannot buy own lot", http.StatusBadRequest)
+		case errs.ErrBuyNowUnavailable:
+			http.Error(w, "buy now is not available", http.StatusConflict)
+		case errs.ErrLotNotStarted:
+			http.Error(w, "auction has not started yet", http.StatusConflict)
+		case errs.ErrLotClosed:
+			http.Error(w, "auction is closed", http.StatusConflict)
+		case errs.ErrLotCancelled:
+			http.Error(w, "auction is cancelled", http.StatusConflict)
+		default:
+			log.Printf("error buying lot: %v", err)
+			http.Error(w, "error buying lot", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(winner)
+}
+
+// @Summary Принятие текущей цены голландского аукциона
+// @Description Покупает лот голландского аукциона по текущей снижающейся цене и завершает аукцион
+// @Tags lots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AcceptPriceRequest true "ID лота"
+// @Success 200 {object} models.Winner "Лот куплен"
+// @Failure 400 {object} models.ErrorResponse "Неверные данные запроса или лот не голландский"
+// @Failure 401 {object} models.ErrorResponse "Не авторизован"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 409 {object} models.ErrorResponse "Аукцион не начался, завершен или отменен"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/lots/accept [post]
+func (h *LotHandler) AcceptPrice(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.AcceptPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	winner, err := h.lotService.AcceptPrice(r.Context(), user.ID, req.LotID)
+	if err != nil {
+		switch err {
+		case errs.ErrInvalidLotID:
+			http.Error(w, "invalid lot ID", http.StatusBadRequest)
+		case errs.ErrFoundLot:
+			http.Error(w, "lot not found", http.StatusNotFound)
+		case errs.ErrEmailNotVerified:
+			http.Error(w, "email not verified", http.StatusForbidden)
+		case errs.ErrCannotBidOnOwnLot:
+			http.Error(w, "cannot buy own lot", http.StatusBadRequest)
+		case errs.ErrNotDutchAuction:
+			http.Error(w, "lot is not a dutch auction", http.StatusBadRequest)
+		case errs.ErrLotNotStarted:
+			http.Error(w, "auction has not started yet", http.StatusConflict)
+		case errs.ErrLotClosed:
+			http.Error(w, "auction is closed", http.StatusConflict)
+		case errs.ErrLotCancelled:
+			http.Error(w, "auction is cancelled", http.StatusConflict)
+		default:
+			log.Printf("error accepting price: %v", err)
+			http.Error(w, "error accepting price", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(winner)
+}
+
 // @Summary Получение лота по ID
 // @Description Возвращает информацию о конкретном лоте по его ID
 // @Tags lots
@@ -138,33 +258,28 @@ func (h *LotHandler) GetLotByID(w http.ResponseWriter, r *http.Request) {
 }
 
 // @Summary Удаление лота
-// @Description Удаляет лот по ID (только для администраторов)
+// @Description Отменяет лот по ID (только для администраторов). Лот и ставки сохраняются в истории,
+// @Description участники торгов получают уведомление
 // @Tags lots
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param id query int true "ID лота для удаления" minimum(1)
-// @Success 204 "Лот успешно удален"
+// @Param reason query string false "Причина отмены"
+// @Success 204 "Лот отменен"
 // @Failure 400 {object} models.ErrorResponse "Неверный ID или отсутствует ID"
 // @Failure 401 {object} models.ErrorResponse "Не авторизован"
 // @Failure 403 {object} models.ErrorResponse "Доступ запрещен (не администратор)"
 // @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 409 {object} models.ErrorResponse "Аукцион уже завершен или отменен"
 // @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
 // @Router /auth/lot/delete [delete]
 func (h *LotHandler) DeleteLot(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUserFromContext(r.Context())
 	if user == nil {
-		log.Println("deleteLot: user is nil")
-		http.Error(w, "admin access required", http.StatusUnauthorized)
-		return
-	}
-
-	if user.Role != "admin" {
-		log.Printf("deleteLot: access denied. Role '%s' != 'admin'", user.Role)
-		http.Error(w, "admin access required", http.StatusUnauthorized)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-
 	idStr := r.URL.Query().Get("id")
 	if idStr == "" {
 		http.Error(w, "lot ID is required", http.StatusBadRequest)
@@ -175,17 +290,226 @@ func (h *LotHandler) DeleteLot(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "invalid lot ID", http.StatusBadRequest)
 		return
 	}
-	err = h.lotService.DeleteLot(r.Context(), id, user.ID)
+	err = h.lotService.DeleteLot(r.Context(), user.ID, id, r.URL.Query().Get("reason"))
+	if err != nil {
+		writeCancelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Отмена лота продавцом
+// @Description Снимает лот с торгов, пока на него нет ставок. Доступно только продавцу
+// @Tags lots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.CancelLotRequest true "ID лота и причина отмены"
+// @Success 204 "Лот отменен"
+// @Failure 400 {object} models.ErrorResponse "Неверные данные запроса"
+// @Failure 401 {object} models.ErrorResponse "Не авторизован"
+// @Failure 403 {object} models.ErrorResponse "Лот принадлежит другому пользователю"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 409 {object} models.ErrorResponse "На лот уже есть ставки или аукцион завершен"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/lots/cancel [post]
+func (h *LotHandler) CancelLot(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.CancelLotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := h.lotService.CancelLot(r.Context(), user.ID, req.LotID, req.Reason); err != nil {
+		writeCancelError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Досрочное завершение аукциона
+// @Description Завершает аукцион досрочно и продает лот текущему лидеру торгов. Доступно только продавцу
+// @Tags lots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.EndLotRequest true "ID лота"
+// @Success 200 {object} models.Winner "Аукцион завершен"
+// @Failure 400 {object} models.ErrorResponse "Неверные данные запроса"
+// @Failure 401 {object} models.ErrorResponse "Не авторизован"
+// @Failure 403 {object} models.ErrorResponse "Лот принадлежит другому пользователю"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 409 {object} models.ErrorResponse "Нет ставок, резервная цена не достигнута или аукцион завершен"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/lots/end [post]
+func (h *LotHandler) EndLot(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.EndLotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	winner, err := h.lotService.EndLotEarly(r.Context(), user.ID, req.LotID)
 	if err != nil {
 		switch err {
-		case errs.ErrAdminAccessDenied:
-			http.Error(w, "access denied", http.StatusForbidden)
+		case errs.ErrNoBids:
+			http.Error(w, "lot has no bids", http.StatusConflict)
+		case errs.ErrReserveNotMet, errs.ErrLotNotStarted:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			writeCancelError(w, err)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(winner)
+}
+
+// @Summary Редактирование лота
+// @Description Изменяет название, описание, стартовую цену и время окончания лота. После первой ставки
+// @Description можно только дописать текст в конец описания. Если что-то изменилось, предыдущая версия
+// @Description сохраняется в истории правок
+// @Tags lots
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UpdateLotRequest true "ID лота и изменяемые поля"
+// @Success 200 {object} models.LotResponse "Обновленный лот"
+// @Failure 400 {object} models.ErrorResponse "Неверные данные запроса"
+// @Failure 401 {object} models.ErrorResponse "Не авторизован"
+// @Failure 403 {object} models.ErrorResponse "Лот принадлежит другому пользователю"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 409 {object} models.ErrorResponse "Изменение недоступно после первой ставки или аукцион завершен"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /auth/lots/update [patch]
+func (h *LotHandler) UpdateLot(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var req models.UpdateLotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	lot, err := h.lotService.UpdateLot(r.Context(), user.ID, req)
+	if err != nil {
+		switch err {
+		case errs.ErrInvalidLotID, errs.ErrNothingToUpdate:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errs.ErrInvalidTitle:
+			http.Error(w, "invalid title", http.StatusBadRequest)
+		case errs.ErrInvalidDescription:
+			http.Error(w, "invalid description", http.StatusBadRequest)
+		case errs.ErrInvalidPrice, errs.ErrInvalidReservePrice, errs.ErrInvalidBuyNowPrice, errs.ErrInvalidDutchLot:
+			http.Error(w, "invalid price", http.StatusBadRequest)
+		case errs.ErrEmptyEndTime, errs.ErrInvalidStartTime:
+			http.Error(w, "invalid end time", http.StatusBadRequest)
+		case errs.ErrNotLotOwner:
+			http.Error(w, err.Error(), http.StatusForbidden)
 		case errs.ErrFoundLot:
 			http.Error(w, "lot not found", http.StatusNotFound)
+		case errs.ErrLotEditRestricted, errs.ErrLotClosed, errs.ErrLotCancelled:
+			http.Error(w, err.Error(), http.StatusConflict)
 		default:
-			log.Printf("error deleting lot: %v", err)
+			log.Printf("error updating lot: %v", err)
+			http.Error(w, "error updating lot", http.StatusInternalServerError)
 		}
 		return
 	}
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lot)
+}
+
+// @Summary История правок лота
+// @Description Возвращает предыдущие версии названия, описания, стартовой цены и времени окончания лота
+// @Tags lots
+// @Produce json
+// @Param id query int true "ID лота" minimum(1)
+// @Success 200 {array} models.LotRevision
+// @Failure 400 {object} models.ErrorResponse "Неверный ID"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/lot/revisions [get]
+func (h *LotHandler) GetLotRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		http.Error(w, "invalid lot ID", http.StatusBadRequest)
+		return
+	}
+	revisions, err := h.lotService.GetLotRevisions(r.Context(), id)
+	if err != nil {
+		switch err {
+		case errs.ErrFoundLot:
+			http.Error(w, "lot not found", http.StatusNotFound)
+		default:
+			log.Printf("error getting lot revisions: %v", err)
+			http.Error(w, "error getting lot revisions", http.StatusInternalServerError)
+		}
+		return
+	}
+	if revisions == nil {
+		revisions = []models.LotRevision{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// @Summary История статусов лота
+// @Description Возвращает все изменения статуса лота: кто и по какой причине его отменил, завершил или продал
+// @Tags lots
+// @Produce json
+// @Param id query int true "ID лота" minimum(1)
+// @Success 200 {array} models.LotStatusChange
+// @Failure 400 {object} models.ErrorResponse "Неверный ID"
+// @Failure 404 {object} models.ErrorResponse "Лот не найден"
+// @Failure 500 {object} models.ErrorResponse "Внутренняя ошибка сервера"
+// @Router /api/lot/history [get]
+func (h *LotHandler) GetLotHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id < 1 {
+		http.Error(w, "invalid lot ID", http.StatusBadRequest)
+		return
+	}
+	history, err := h.lotService.GetLotHistory(r.Context(), id)
+	if err != nil {
+		switch err {
+		case errs.ErrFoundLot:
+			http.Error(w, "lot not found", http.StatusNotFound)
+		default:
+			log.Printf("error getting lot history: %v", err)
+			http.Error(w, "error getting lot history", http.StatusInternalServerError)
+		}
+		return
+	}
+	if history == nil {
+		history = []models.LotStatusChange{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+func writeCancelError(w http.ResponseWriter, err error) {
+	switch err {
+	case errs.ErrInvalidLotID, errs.ErrReasonTooLong:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errs.ErrNotLotOwner:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errs.ErrFoundLot:
+		http.Error(w, "lot not found", http.StatusNotFound)
+	case errs.ErrLotHasBids, errs.ErrLotClosed, errs.ErrLotCancelled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("error changing lot status: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"auction/internal/errs"
+	"auction/internal/middleware"
+	"auction/internal/models"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// @Summary Подключение двухфакторной аутентификации
+// @Description Создает TOTP-секрет и otpauth URI для приложения-аутентификатора.
+// @Description Двухфакторная аутентификация включается после подтверждения кодом
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.MFAEnrollResponse
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/enroll [post]
+func (h *AuthHandler) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := h.authService.EnrollMFA(r.Context(), user.ID)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// @Summary Подтверждение двухфакторной аутентификации
+// @Description Проверяет первый TOTP-код, включает двухфакторную аутентификацию и возвращает коды восстановления
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "Код из приложения-аутентификатора"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/confirm [post]
+func (h *AuthHandler) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.authService.ConfirmMFA(r.Context(), user.ID, req.Code)
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Новые коды восстановления
+// @Description Заменяет коды восстановления новыми. Требует действующий TOTP-код
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "Код из приложения-аутентификатора"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/recovery-codes [post]
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(r.Context(), user.ID, req.Code, clientIP(r))
+	if err != nil {
+		writeMFAError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Отключение двухфакторной аутентификации
+// @Description Отключает двухфакторную аутентификацию и удаляет коды восстановления. Требует действующий TOTP-код
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.MFACodeRequest true "Код из приложения-аутентификатора"
+// @Success 204 "Двухфакторная аутентификация отключена"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/mfa/disable [post]
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.DisableMFA(r.Context(), user.ID, req.Code, clientIP(r)); err != nil {
+		writeMFAError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Второй шаг входа
+// @Description Обменивает MFA-токен из /api/login и TOTP-код или код восстановления на пару токенов
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.MFALoginRequest true "MFA-токен и код"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/login/mfa [post]
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var req models.MFALoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.authService.LoginMFA(r.Context(), req, clientIP(r))
+	if err != nil {
+		writeLoginError(w, result, err)
+		return
+	}
+	writeLoginResult(w, result)
+}
+
+func writeMFAError(w http.ResponseWriter, err error) {
+	switch err {
+	case errs.ErrMFAAlreadyEnabled:
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errs.ErrMFANotEnabled, errs.ErrMFACodeInvalid:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errs.ErrTooManyAttempts:
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		log.Printf("error managing two-factor authentication: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
+}
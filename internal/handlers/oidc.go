@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"auction/internal/errs"
+	"auction/internal/service"
+	"log"
+	"net/http"
+)
+
+// oidcStateCookie holds the binding that ties a login's state to the browser
+// that started it.
+const oidcStateCookie = "oidc_state"
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+// @Summary Вход через внешнего провайдера
+// @Description Перенаправляет пользователя на страницу входа OpenID Connect провайдера (authorization code + PKCE)
+// @Description и устанавливает cookie oidc_state, привязывающую вход к браузеру
+// @Tags auth
+// @Produce json
+// @Param provider query string true "Имя провайдера"
+// @Success 302 "Перенаправление на провайдера"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/oidc/login [get]
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	redirectURL, binding, err := h.oidcService.Start(r.Context(), r.URL.Query().Get("provider"))
+	switch err {
+	case nil:
+		setOIDCStateCookie(w, binding, 0)
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+	case errs.ErrUnknownProvider:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("error starting oidc login: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
+}
+
+// @Summary Возврат от внешнего провайдера
+// @Description Обменивает код авторизации на пару токенов. Внешняя учетная запись привязывается к пользователю
+// @Description с тем же подтвержденным email или к новому пользователю. Если включена двухфакторная
+// @Description аутентификация, вместо токенов возвращается MFA-токен для /api/login/mfa. Требует cookie
+// @Description oidc_state, установленную при входе в том же браузере
+// @Tags auth
+// @Produce json
+// @Param state query string true "Параметр state"
+// @Param code query string true "Код авторизации"
+// @Success 200 {object} models.AuthResponse
+// @Success 202 {object} models.MFAChallengeResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/oidc/callback [get]
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "login rejected by identity provider: "+providerErr, http.StatusUnauthorized)
+		return
+	}
+	if query.Get("state") == "" || query.Get("code") == "" {
+		http.Error(w, "state and code are required", http.StatusBadRequest)
+		return
+	}
+
+	// A missing cookie leaves the binding empty, which the service refuses.
+	var binding string
+	if cookie, err := r.Cookie(oidcStateCookie); err == nil {
+		binding = cookie.Value
+	}
+	setOIDCStateCookie(w, "", -1)
+
+	result, err := h.oidcService.Callback(r.Context(), query.Get("state"), binding, query.Get("code"))
+	switch err {
+	case nil:
+		writeLoginResult(w, result)
+	case errs.ErrOIDCStateInvalid, errs.ErrOIDCEmailMissing, errs.ErrUnknownProvider:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errs.ErrInvalidCredentials:
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+	case errs.ErrEmailAlreadyExists:
+		http.Error(w, "email belongs to an existing account", http.StatusConflict)
+	default:
+		log.Printf("error completing oidc login: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
+}
+
+// setOIDCStateCookie sets the state binding for the callback, or clears it
+// when maxAge is negative. SameSite=Lax still sends it on the provider's
+// top-level redirect back.
+func setOIDCStateCookie(w http.ResponseWriter, binding string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    binding,
+		Path:     "/api/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"auction/internal/errs"
+	"auction/internal/middleware"
+	"auction/internal/models"
+	"auction/internal/service"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+type RoleHandler struct {
+	roleService *service.RoleService
+}
+
+func NewRoleHandler(roleService *service.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+	}
+}
+
+// @Summary Список ролей
+// @Description Возвращает роли и их разрешения
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/admin/roles [get]
+func (h *RoleHandler) GetRoles(w http.ResponseWriter, r *http.Request) {
+	roles, err := h.roleService.GetRoles(r.Context())
+	if err != nil {
+		log.Printf("error getting roles: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+		return
+	}
+	if roles == nil {
+		roles = []models.Role{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(roles)
+}
+
+// @Summary Назначение роли
+// @Description Назначает пользователю роль. Собственную роль изменить нельзя
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.AssignRoleRequest true "Пользователь и роль"
+// @Success 204 "Роль назначена"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/admin/users/role [post]
+func (h *RoleHandler) AssignRole(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID < 1 {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.roleService.AssignRole(r.Context(), user.ID, req.UserID, req.Role); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Блокировка пользователя
+// @Description Переводит пользователя в роль banned и завершает все его сессии
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.BanUserRequest true "Пользователь и причина"
+// @Success 204 "Пользователь заблокирован"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/admin/users/ban [post]
+func (h *RoleHandler) BanUser(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.BanUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.UserID < 1 {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.roleService.BanUser(r.Context(), user.ID, req.UserID, req.Reason); err != nil {
+		writeRoleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeRoleError(w http.ResponseWriter, err error) {
+	switch err {
+	case errs.ErrCannotChangeOwnRole, errs.ErrRoleNotFound:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errs.ErrUserNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("error changing user role: %v", err)
+		http.Error(w, "error", http.StatusInternalServerError)
+	}
+}
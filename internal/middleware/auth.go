@@ -1,21 +1,47 @@
 package middleware
 
 import (
+	"auction/internal/errs"
 	"auction/internal/models"
 	"auction/internal/pkg"
+	"auction/internal/repository"
 	"context"
-	"fmt"
-	"github.com/golang-jwt/jwt/v4"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 )
 
-const UserKey = "user"
+const (
+	UserKey   = "user"
+	ClaimsKey = "claims"
+	APIKeyKey = "api_key"
 
-func AuthMiddleware(h http.Handler) http.Handler {
+	APIKeyHeader = "X-API-Key"
+)
+
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*models.User, *models.APIKey, error)
+}
+
+type Auth struct {
+	revocations repository.TokenRevocationRepository
+	apiKeys     APIKeyAuthenticator
+}
+
+func NewAuth(revocations repository.TokenRevocationRepository, apiKeys APIKeyAuthenticator) *Auth {
+	return &Auth{revocations: revocations, apiKeys: apiKeys}
+}
+
+// AuthMiddleware accepts either a Bearer access token or an API key in the
+// X-API-Key header.
+func (a *Auth) AuthMiddleware(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+			a.serveAPIKey(w, r, h, apiKey)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
 			log.Println("no authorization header")
@@ -29,21 +55,26 @@ func AuthMiddleware(h http.Handler) http.Handler {
 			return
 		}
 
-		claims := &pkg.CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
+		claims, err := pkg.ValidateToken(tokenString, pkg.TokenTypeAccess)
 		if err != nil {
 			log.Printf("ERROR parsing token: %v", err)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
-		if !token.Valid {
-			log.Println("Token invalid token")
+
+		var issuedAt time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time
+		}
+		revoked, err := a.revocations.IsTokenRevoked(r.Context(), claims.ID, claims.UserID, issuedAt,
+			claims.TokenVersion)
+		if err != nil {
+			log.Printf("ERROR checking token revocation: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if revoked {
+			log.Printf("revoked token used by user %d", claims.UserID)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
@@ -59,10 +90,31 @@ func AuthMiddleware(h http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), UserKey, user)
+		ctx = context.WithValue(ctx, ClaimsKey, claims)
 		h.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+func (a *Auth) serveAPIKey(w http.ResponseWriter, r *http.Request, h http.Handler, apiKey string) {
+	user, key, err := a.apiKeys.AuthenticateAPIKey(r.Context(), apiKey)
+	if err == errs.ErrInvalidAPIKey {
+		log.Println("invalid api key")
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("ERROR checking api key: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("user authenticated by api key %d - ID: %d, Username: %s", key.ID, user.ID, user.Username)
+
+	ctx := context.WithValue(r.Context(), UserKey, user)
+	ctx = context.WithValue(ctx, APIKeyKey, key)
+	h.ServeHTTP(w, r.WithContext(ctx))
+}
+
 func GetUserFromContext(ctx context.Context) *models.User {
 	user, ok := ctx.Value(UserKey).(*models.User)
 	if !ok {
@@ -70,3 +122,21 @@ func GetUserFromContext(ctx context.Context) *models.User {
 	}
 	return user
 }
+
+func GetClaimsFromContext(ctx context.Context) *pkg.CustomClaims {
+	claims, ok := ctx.Value(ClaimsKey).(*pkg.CustomClaims)
+	if !ok {
+		return nil
+	}
+	return claims
+}
+
+// GetAPIKeyFromContext returns the API key the request was authenticated
+// with, or nil for requests authenticated with a token.
+func GetAPIKeyFromContext(ctx context.Context) *models.APIKey {
+	key, ok := ctx.Value(APIKeyKey).(*models.APIKey)
+	if !ok {
+		return nil
+	}
+	return key
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, userID int, permission string) (bool, error)
+}
+
+// RequirePermission rejects requests whose user lacks permission, or whose API
+// key is not scoped for it. It must run after AuthMiddleware, which puts the
+// user into the request context.
+func RequirePermission(checker PermissionChecker, permission string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r.Context())
+			if user == nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if key := GetAPIKeyFromContext(r.Context()); key != nil && !key.HasScope(permission) {
+				log.Printf("api key %d is not scoped for %s", key.ID, permission)
+				http.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+			allowed, err := checker.HasPermission(r.Context(), user.ID, permission)
+			if err != nil {
+				log.Printf("ERROR checking permission %s: %v", permission, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				log.Printf("user %d denied permission %s", user.ID, permission)
+				http.Error(w, "permission denied", http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireToken rejects requests authenticated with an API key. Account
+// management stays reachable only from an interactive login.
+func RequireToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetAPIKeyFromContext(r.Context()) != nil {
+			http.Error(w, "not available with api keys", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// APIKey is a long-lived credential for bots and integrations. Scopes are
+// permission names; a key can do what both its scopes and its owner's role
+// allow.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k *APIKey) HasScope(permission string) bool {
+	return slices.Contains(k.Scopes, permission)
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse carries the only copy of the secret key; it is not
+// stored and cannot be shown again.
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
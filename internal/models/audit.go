@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	AuditEventUserLockout  = "login_user_lockout"
+	AuditEventIPLockout    = "login_ip_lockout"
+	AuditEventRoleAssigned = "role_assigned"
+	AuditEventUserBanned   = "user_banned"
+)
+
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	Event     string    `json:"event"`
+	UserID    *int      `json:"user_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -11,14 +11,16 @@ type Bid struct {
 }
 
 type PlaceBidRequest struct {
-	LotID  int `json:"lot_id"`
-	Amount int `json:"amount"`
+	LotID     int `json:"lot_id"`
+	Amount    int `json:"amount"`
+	MaxAmount int `json:"max_amount,omitempty"`
 }
 
 type PlaceBid struct {
-	ID     int `json:"id"`
-	Amount int `json:"amount"`
-	LotID  int `json:"lot_id"`
+	ID        int `json:"id"`
+	Amount    int `json:"amount"`
+	MaxAmount int `json:"max_amount"`
+	LotID     int `json:"lot_id"`
 }
 
 type BidCreate struct {
@@ -28,10 +30,21 @@ type BidCreate struct {
 }
 
 type BidResponse struct {
-	ID     int `json:"id"`
-	LotID  int `json:"lot_id"`
-	UserID int `json:"user_id"`
-	Amount int `json:"amount"`
+	ID           int       `json:"id"`
+	LotID        int       `json:"lot_id"`
+	UserID       int       `json:"user_id"`
+	Amount       int       `json:"amount"`
+	MaxAmount    int       `json:"max_amount,omitempty"`
+	CurrentPrice int       `json:"current_price"`
+	Leading      bool      `json:"leading"`
+	EndTime      time.Time `json:"end_time"`
+}
+
+type ProxyBid struct {
+	LotID     int       `json:"lot_id"`
+	UserID    int       `json:"user_id"`
+	MaxAmount int       `json:"max_amount"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 type UserBidsResponse struct {
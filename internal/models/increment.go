@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type IncrementStep struct {
+	From      int `json:"from"`
+	Increment int `json:"increment"`
+}
+
+// IncrementSchedule lists minimum bid increments by price band, ordered by From.
+type IncrementSchedule []IncrementStep
+
+// Increment returns the minimum raise allowed at the given price.
+func (s IncrementSchedule) Increment(price int) int {
+	increment := 1
+	for _, step := range s {
+		if price < step.From {
+			break
+		}
+		increment = step.Increment
+	}
+	return increment
+}
+
+func (s IncrementSchedule) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *IncrementSchedule) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into IncrementSchedule", src)
+	}
+}
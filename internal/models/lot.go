@@ -4,41 +4,108 @@ import (
 	"time"
 )
 
+const (
+	LotStatusActive    = "active"
+	LotStatusClosed    = "closed"
+	LotStatusCancelled = "cancelled"
+	LotStatusUnsold    = "unsold"
+)
+
+const (
+	AuctionTypeEnglish           = "english"
+	AuctionTypeSealedFirstPrice  = "sealed_first_price"
+	AuctionTypeSealedSecondPrice = "sealed_second_price"
+	AuctionTypeDutch             = "dutch"
+)
+
 type LotCreate struct {
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	StartPrice   int       `json:"start_price"`
-	CurrentPrice int       `json:"current_price"`
-	Status       string    `json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
-	UserID       int       `json:"user_id"`
-	EndTime      time.Time `json:"end_time"`
+	Title             string            `json:"title"`
+	Description       string            `json:"description"`
+	StartPrice        int               `json:"start_price"`
+	CurrentPrice      int               `json:"current_price"`
+	Status            string            `json:"status"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UserID            int               `json:"user_id"`
+	StartTime         time.Time         `json:"start_time"`
+	EndTime           time.Time         `json:"end_time"`
+	IncrementSchedule IncrementSchedule `json:"increment_schedule"`
+	ReservePrice      int               `json:"reserve_price"`
+	BuyNowPrice       int               `json:"buy_now_price"`
+	AuctionType       string            `json:"auction_type"`
+	FloorPrice        int               `json:"floor_price"`
+	PriceDecrement    int               `json:"price_decrement"`
+	DecrementSeconds  int               `json:"decrement_seconds"`
 }
 
 type CreateLotRequest struct {
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	StartPrice  int       `json:"start_price"`
-	EndTime     time.Time `json:"end_time"`
+	Title             string            `json:"title"`
+	Description       string            `json:"description"`
+	StartPrice        int               `json:"start_price"`
+	StartTime         time.Time         `json:"start_time"`
+	EndTime           time.Time         `json:"end_time"`
+	IncrementSchedule IncrementSchedule `json:"increment_schedule,omitempty"`
+	ReservePrice      int               `json:"reserve_price,omitempty"`
+	BuyNowPrice       int               `json:"buy_now_price,omitempty"`
+	AuctionType       string            `json:"auction_type,omitempty"`
+	FloorPrice        int               `json:"floor_price,omitempty"`
+	PriceDecrement    int               `json:"price_decrement,omitempty"`
+	DecrementSeconds  int               `json:"decrement_seconds,omitempty"`
 }
 
 type Lot struct {
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	StartPrice  int       `json:"start_price"`
-	EndTime     time.Time `json:"end_time"`
+	Title             string            `json:"title"`
+	Description       string            `json:"description"`
+	StartPrice        int               `json:"start_price"`
+	StartTime         time.Time         `json:"start_time"`
+	EndTime           time.Time         `json:"end_time"`
+	IncrementSchedule IncrementSchedule `json:"increment_schedule,omitempty"`
+	ReservePrice      int               `json:"reserve_price,omitempty"`
+	BuyNowPrice       int               `json:"buy_now_price,omitempty"`
+	AuctionType       string            `json:"auction_type,omitempty"`
+	FloorPrice        int               `json:"floor_price,omitempty"`
+	PriceDecrement    int               `json:"price_decrement,omitempty"`
+	DecrementSeconds  int               `json:"decrement_seconds,omitempty"`
 }
 
 type LotResponse struct {
-	ID           int       `json:"id"`
-	Title        string    `json:"title"`
-	Description  string    `json:"description"`
-	StartPrice   int       `json:"start_price"`
-	CurrentPrice int       `json:"current_price"`
-	EndTime      time.Time `json:"end_time"`
-	CreatedAt    time.Time `json:"created_at"`
-	UserID       int       `json:"user_id"`
+	ID                int               `json:"id"`
+	Title             string            `json:"title"`
+	Description       string            `json:"description"`
+	StartPrice        int               `json:"start_price"`
+	CurrentPrice      int               `json:"current_price,omitempty"`
+	StartTime         time.Time         `json:"start_time"`
+	EndTime           time.Time         `json:"end_time"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UserID            int               `json:"user_id"`
+	Status            string            `json:"status"`
+	IncrementSchedule IncrementSchedule `json:"increment_schedule,omitempty"`
+	NextMinimumBid    int               `json:"next_minimum_bid,omitempty"`
+	ExtensionCount    int               `json:"extension_count"`
+	ReservePrice      int               `json:"-"`
+	ReserveMet        bool              `json:"reserve_met"`
+	BuyNowPrice       int               `json:"buy_now_price,omitempty"`
+	AuctionType       string            `json:"auction_type"`
+	FloorPrice        int               `json:"floor_price,omitempty"`
+	PriceDecrement    int               `json:"price_decrement,omitempty"`
+	DecrementSeconds  int               `json:"decrement_seconds,omitempty"`
+	Winner            *Winner           `json:"winner,omitempty"`
 }
+
+type Winner struct {
+	LotID   int       `json:"lot_id"`
+	UserID  int       `json:"user_id"`
+	Amount  int       `json:"amount"`
+	WinDate time.Time `json:"win_date"`
+}
+
+type BuyNowRequest struct {
+	LotID int `json:"lot_id"`
+}
+
+type AcceptPriceRequest struct {
+	LotID int `json:"lot_id"`
+}
+
 type CreateLotResponse struct {
 	Message string `json:"message"`
 	LotID   int    `json:"lot_id"`
@@ -47,3 +114,68 @@ type CreateLotResponse struct {
 type ErrorResponse struct {
 	Error string `json:"error" example:"error message"`
 }
+
+// Reasons recorded with automatic status transitions.
+const (
+	LotReasonExpired        = "auction ended"
+	LotReasonReserveNotMet  = "reserve price not met"
+	LotReasonNoBids         = "no bids"
+	LotReasonBuyNow         = "bought now"
+	LotReasonPriceAccepted  = "dutch price accepted"
+	LotReasonEndedBySeller  = "ended early by seller"
+	LotReasonCancelled      = "cancelled by seller"
+	LotReasonRemovedByAdmin = "removed by administrator"
+)
+
+// LotStatusChange is one recorded status transition. ActorID is nil for
+// transitions made by the system, such as the auction closer.
+type LotStatusChange struct {
+	ID         int       `json:"id"`
+	LotID      int       `json:"lot_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    *int      `json:"actor_id,omitempty"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type CancelLotRequest struct {
+	LotID  int    `json:"lot_id"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type EndLotRequest struct {
+	LotID int `json:"lot_id"`
+}
+
+// UpdateLotRequest changes only the fields that are set. Once the lot has
+// bids, the description may only be extended.
+type UpdateLotRequest struct {
+	LotID       int        `json:"lot_id"`
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	StartPrice  *int       `json:"start_price,omitempty"`
+	EndTime     *time.Time `json:"end_time,omitempty"`
+}
+
+// LotUpdate holds the complete editable state written back to the lot.
+type LotUpdate struct {
+	Title        string
+	Description  string
+	StartPrice   int
+	CurrentPrice int
+	EndTime      time.Time
+}
+
+// LotRevision is the state of a lot's editable fields before an edit by
+// EditorID.
+type LotRevision struct {
+	ID          int       `json:"id"`
+	LotID       int       `json:"lot_id"`
+	EditorID    int       `json:"editor_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	StartPrice  int       `json:"start_price"`
+	EndTime     time.Time `json:"end_time"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// OIDCLoginState is the server-side half of an authorization request, looked
+// up by the state parameter when the provider redirects back.
+type OIDCLoginState struct {
+	State        string
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// UserIdentity links an account at an external identity provider to a user.
+type UserIdentity struct {
+	Provider string
+	Subject  string
+	UserID   int
+	Email    string
+}
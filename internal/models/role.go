@@ -0,0 +1,35 @@
+package models
+
+const (
+	RoleUser   = "user"
+	RoleAdmin  = "admin"
+	RoleBanned = "banned"
+)
+
+const (
+	PermLotCreate     = "lot:create"
+	PermLotBuy        = "lot:buy"
+	PermLotManage     = "lot:manage"
+	PermLotDelete     = "lot:delete"
+	PermBidPlace      = "bid:place"
+	PermBidReadOwn    = "bid:read_own"
+	PermSessionRevoke = "session:revoke"
+	PermUserBan       = "user:ban"
+	PermRoleAssign    = "role:assign"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	UserID int    `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+type BanUserRequest struct {
+	UserID int    `json:"user_id"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,356 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "auction-client"
+	testClientSecret = "auction-secret"
+	testRedirectURL  = "http://app.test/api/oidc/callback"
+	testKeyID        = "key-1"
+)
+
+// mockIssuer is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that redeems codes registered with authorize.
+type mockIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// discoveredIssuer overrides the issuer in the discovery document.
+	discoveredIssuer string
+
+	mu          sync.Mutex
+	grants      map[string]mockGrant
+	jwksFetches int
+}
+
+type mockGrant struct {
+	challenge string
+	idToken   string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	m := &mockIssuer{key: newRSAKey(t), grants: map[string]mockGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) issuer() string {
+	return m.server.URL
+}
+
+func (m *mockIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := m.issuer()
+	if m.discoveredIssuer != "" {
+		issuer = m.discoveredIssuer
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 issuer,
+		"authorization_endpoint": m.issuer() + "/authorize",
+		"token_endpoint":         m.issuer() + "/token",
+		"jwks_uri":               m.issuer() + "/jwks",
+	})
+}
+
+func (m *mockIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.jwksFetches++
+	m.mu.Unlock()
+	pub := m.key.PublicKey
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": testKeyID,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	if r.PostForm.Get("client_id") != testClientID || r.PostForm.Get("client_secret") != testClientSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.grants[r.PostForm.Get("code")]
+	delete(m.grants, r.PostForm.Get("code"))
+	m.mu.Unlock()
+	if !ok || CodeChallengeS256(r.PostForm.Get("code_verifier")) != grant.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     grant.idToken,
+	})
+}
+
+// authorize registers a code that redeems to idToken when presented with the
+// verifier matching challenge.
+func (m *mockIssuer) authorize(challenge, idToken string) string {
+	code := NewRandomString()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.grants[code] = mockGrant{challenge: challenge, idToken: idToken}
+	return code
+}
+
+func (m *mockIssuer) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            m.issuer(),
+		"sub":            "subject-1",
+		"aud":            testClientID,
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"nonce":          nonce,
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return key
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing id token: %v", err)
+	}
+	return signed
+}
+
+func discoverMock(t *testing.T, m *mockIssuer) *GenericProvider {
+	t.Helper()
+	provider, err := Discover(context.Background(), "mock", Config{
+		IssuerURL:    m.issuer(),
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, m.server.Client())
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	return provider
+}
+
+func TestDiscover(t *testing.T) {
+	m := newMockIssuer(t)
+	provider := discoverMock(t, m)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", "challenge-1"))
+	if err != nil {
+		t.Fatalf("parsing auth url: %v", err)
+	}
+	if !strings.HasPrefix(authURL.String(), m.issuer()+"/authorize?") {
+		t.Fatalf("unexpected authorization endpoint %s", authURL)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          testRedirectURL,
+		"scope":                 "openid email profile",
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        "challenge-1",
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := authURL.Query().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	m := newMockIssuer(t)
+	m.discoveredIssuer = "https://evil.example.com"
+	_, err := Discover(context.Background(), "mock", Config{IssuerURL: m.issuer(), ClientID: testClientID},
+		m.server.Client())
+	if err == nil {
+		t.Fatal("want an error for a discovery document naming another issuer")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	otherKey := newRSAKey(t)
+	tests := []struct {
+		name string
+		// idToken builds the token the provider returns for the expected nonce.
+		idToken  func(t *testing.T, m *mockIssuer, nonce string) string
+		verifier func(verifier string) string
+		wantErr  error
+	}{
+		{
+			name: "valid",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				return sign(t, m.key, testKeyID, m.claims(nonce))
+			},
+		},
+		{
+			name: "multiple audiences with matching azp",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				claims := m.claims(nonce)
+				claims["aud"] = []string{testClientID, "other-client"}
+				claims["azp"] = testClientID
+				return sign(t, m.key, testKeyID, claims)
+			},
+		},
+		{
+			name: "bad nonce",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				return sign(t, m.key, testKeyID, m.claims("another nonce"))
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "bad audience",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				claims := m.claims(nonce)
+				claims["aud"] = "other-client"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "bad authorized party",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				claims := m.claims(nonce)
+				claims["aud"] = []string{testClientID, "other-client"}
+				claims["azp"] = "other-client"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "bad issuer",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				claims := m.claims(nonce)
+				claims["iss"] = "https://evil.example.com"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "expired",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				claims := m.claims(nonce)
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "missing expiry",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				claims := m.claims(nonce)
+				delete(claims, "exp")
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "unknown kid",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				return sign(t, otherKey, "key-2", m.claims(nonce))
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "known kid signed with another key",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				return sign(t, otherKey, testKeyID, m.claims(nonce))
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "bad code verifier",
+			idToken: func(t *testing.T, m *mockIssuer, nonce string) string {
+				return sign(t, m.key, testKeyID, m.claims(nonce))
+			},
+			verifier: func(verifier string) string { return verifier + "x" },
+			wantErr:  ErrExchangeFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockIssuer(t)
+			provider := discoverMock(t, m)
+
+			verifier := NewRandomString()
+			nonce := NewRandomString()
+			code := m.authorize(CodeChallengeS256(verifier), tt.idToken(t, m, nonce))
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+
+			identity, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if identity.Subject != "subject-1" || identity.Email != "alice@example.com" ||
+				!identity.EmailVerified || identity.Name != "Alice" {
+				t.Fatalf("unexpected identity %+v", identity)
+			}
+		})
+	}
+}
+
+func TestExchangeUnknownKidRefetchIsLimited(t *testing.T) {
+	m := newMockIssuer(t)
+	provider := discoverMock(t, m)
+	otherKey := newRSAKey(t)
+
+	for i := 0; i < 3; i++ {
+		verifier := NewRandomString()
+		nonce := NewRandomString()
+		code := m.authorize(CodeChallengeS256(verifier), sign(t, otherKey, "key-2", m.claims(nonce)))
+		if _, err := provider.Exchange(context.Background(), code, verifier, nonce); !errors.Is(err, ErrInvalidIDToken) {
+			t.Fatalf("attempt %d: want ErrInvalidIDToken, got %v", i+1, err)
+		}
+	}
+	if m.jwksFetches != 1 {
+		t.Fatalf("unknown kids fetched the key set %d times, want 1", m.jwksFetches)
+	}
+}
@@ -1,58 +1,151 @@
 package pkg
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"github.com/golang-jwt/jwt/v4"
-	"os"
 	"time"
 )
 
+const (
+	TokenTypeAccess            = "access"
+	TokenTypeRefresh           = "refresh"
+	TokenTypeEmailVerification = "email_verification"
+	TokenTypeMFAChallenge      = "mfa_challenge"
+
+	AccessTokenTTL            = 1 * time.Hour
+	RefreshTokenTTL           = 7 * 24 * time.Hour
+	EmailVerificationTokenTTL = 24 * time.Hour
+	MFAChallengeTokenTTL      = 5 * time.Minute
+)
+
+var (
+	ErrInvalidToken     = errors.New("invalid token")
+	ErrWrongTokenType   = errors.New("wrong token type")
+	ErrUnknownTokenType = errors.New("unknown token type")
+)
+
+var (
+	keys     *KeyManager
+	issuer   = "auction"
+	audience = "auction-api"
+)
+
+// SetKeyManager selects the keys GenerateToken and ValidateToken use.
+func SetKeyManager(m *KeyManager) {
+	keys = m
+}
+
+// SetIssuer sets the iss and aud claims written to and required of every token.
+func SetIssuer(iss, aud string) {
+	issuer = iss
+	audience = aud
+}
+
+func tokenTTL(tokenType string) (time.Duration, error) {
+	switch tokenType {
+	case TokenTypeAccess:
+		return AccessTokenTTL, nil
+	case TokenTypeRefresh:
+		return RefreshTokenTTL, nil
+	case TokenTypeEmailVerification:
+		return EmailVerificationTokenTTL, nil
+	case TokenTypeMFAChallenge:
+		return MFAChallengeTokenTTL, nil
+	default:
+		return 0, ErrUnknownTokenType
+	}
+}
+
 type CustomClaims struct {
 	UserID    int    `json:"user_id"`
 	Username  string `json:"username"`
 	Email     string `json:"email"`
 	Role      string `json:"role"`
 	TokenType string `json:"token_type"`
+	FamilyID  string `json:"fid,omitempty"`
+	// TokenVersion is the user's token version at issue time. Revoking all
+	// sessions bumps the version, which rejects every older token.
+	TokenVersion int `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID int, username, email, role, tokenType string) (string, error) {
-	var expirationTime time.Time
+func GenerateToken(userID int, username, email, role, tokenType string, version int) (string, error) {
+	token, _, err := GenerateTokenInFamily(userID, username, email, role, tokenType, "", version)
+	return token, err
+}
 
-	if tokenType == "access" {
-		expirationTime = time.Now().Add(1 * time.Hour)
-	} else if tokenType == "refresh" {
-		expirationTime = time.Now().Add(7 * 24 * time.Hour)
+// GenerateTokenInFamily signs a token with a fresh jti. Refresh tokens issued by
+// rotation share the familyID of the login that started the chain.
+func GenerateTokenInFamily(userID int, username, email, role, tokenType, familyID string,
+	version int) (string, *CustomClaims, error) {
+	ttl, err := tokenTTL(tokenType)
+	if err != nil {
+		return "", nil, err
 	}
+	now := time.Now()
 
 	claims := &CustomClaims{
-		UserID:    userID,
-		Username:  username,
-		Email:     email,
-		Role:      role,
-		TokenType: tokenType,
+		UserID:       userID,
+		Username:     username,
+		Email:        email,
+		Role:         role,
+		TokenType:    tokenType,
+		FamilyID:     familyID,
+		TokenVersion: version,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        NewTokenID(),
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if keys == nil {
+		return "", nil, ErrKeysNotConfigured
+	}
+	signed, err := keys.Sign(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-func ValidateToken(tokenStr string) (*CustomClaims, error) {
+func NewTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidateToken is the single check for incoming tokens: signature, expiry,
+// issuer, audience and that the token is of the expected type.
+func ValidateToken(tokenStr string, tokenType string) (*CustomClaims, error) {
+	if keys == nil {
+		return nil, ErrKeysNotConfigured
+	}
 	claims := &CustomClaims{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenStr, claims, keys.keyFunc)
 
 	if err != nil {
 		return nil, err
 	}
 
 	if !token.Valid {
-		return nil, errors.New("invalid token")
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+	if !claims.VerifyIssuer(issuer, true) || !claims.VerifyAudience(audience, true) {
+		return nil, ErrInvalidToken
+	}
+	if claims.TokenType != tokenType {
+		return nil, ErrWrongTokenType
 	}
 
 	return claims, nil
@@ -0,0 +1,294 @@
+package pkg
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyReloadInterval limits how often a token with an unknown kid or a JWKS
+// request makes the manager re-read the key directory, so such requests
+// cannot be used to make every request hit the disk.
+const keyReloadInterval = time.Minute
+
+var (
+	ErrUnknownKey        = errors.New("unknown signing key")
+	ErrKeysNotConfigured = errors.New("signing keys not configured")
+)
+
+type signingKey struct {
+	id        string
+	private   crypto.Signer
+	createdAt time.Time
+}
+
+// KeyManager holds the asymmetric keys used to sign and verify tokens. The
+// newest key signs; older keys stay available for verification for the
+// retention period after they were replaced. Keys are persisted as PKCS#8
+// PEM files named <kid>.pem when a directory is configured, so several
+// instances can share them.
+type KeyManager struct {
+	mu        sync.RWMutex
+	method    jwt.SigningMethod
+	dir       string
+	retention time.Duration
+	keys      []*signingKey
+
+	reloadMu sync.Mutex
+	loadedAt time.Time
+}
+
+func NewKeyManager(algorithm, dir string, retention time.Duration) (*KeyManager, error) {
+	var method jwt.SigningMethod
+	switch algorithm {
+	case "RS256":
+		method = jwt.SigningMethodRS256
+	case "EdDSA":
+		method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	m := &KeyManager{method: method, dir: dir, retention: retention}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+		if err := m.load(true); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.keys) == 0 {
+		if err := m.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Rotate generates a new signing key and drops keys retired for longer than
+// the retention period.
+func (m *KeyManager) Rotate() error {
+	key, err := m.generate()
+	if err != nil {
+		return err
+	}
+	if m.dir != "" {
+		if err := m.save(key); err != nil {
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys = append(m.keys, key)
+	m.prune(time.Now(), true)
+	return nil
+}
+
+// Run rotates the signing key every interval until ctx is cancelled.
+func (m *KeyManager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Rotate(); err != nil {
+				log.Printf("error rotating signing key: %v", err)
+			}
+		}
+	}
+}
+
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	m.mu.RLock()
+	key := m.keys[len(m.keys)-1]
+	m.mu.RUnlock()
+
+	token := jwt.NewWithClaims(m.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+func (m *KeyManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != m.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	if key := m.find(kid); key != nil {
+		return key.private.Public(), nil
+	}
+	if m.dir != "" && m.reload() {
+		if key := m.find(kid); key != nil {
+			return key.private.Public(), nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// reload re-reads the key directory to pick up keys rotated by another
+// instance, at most once per keyReloadInterval. It reports whether it did.
+func (m *KeyManager) reload() bool {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+	if time.Since(m.loadedAt) < keyReloadInterval {
+		return false
+	}
+	m.loadedAt = time.Now()
+	if err := m.load(false); err != nil {
+		log.Printf("error reloading signing keys: %v", err)
+	}
+	return true
+}
+
+func (m *KeyManager) find(kid string) *signingKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, key := range m.keys {
+		if key.id == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// prune drops keys retired for longer than the retention period. Their files
+// are only deleted when removeFiles is set, which the token verification
+// path never does.
+func (m *KeyManager) prune(now time.Time, removeFiles bool) {
+	kept := m.keys[:0]
+	for i, key := range m.keys {
+		if i < len(m.keys)-1 && m.keys[i+1].createdAt.Add(m.retention).Before(now) {
+			if m.dir != "" && removeFiles {
+				os.Remove(filepath.Join(m.dir, key.id+".pem"))
+			}
+			continue
+		}
+		kept = append(kept, key)
+	}
+	m.keys = kept
+}
+
+func (m *KeyManager) generate() (*signingKey, error) {
+	var private crypto.Signer
+	var err error
+	switch m.method {
+	case jwt.SigningMethodEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &signingKey{id: NewTokenID()[:16], private: private, createdAt: time.Now()}, nil
+}
+
+func (m *KeyManager) save(key *signingKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	// Write under a name load does not match and rename it into place, so
+	// other instances never read a partially written key.
+	tmp, err := os.CreateTemp(m.dir, key.id+".pem.tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), key.createdAt, key.createdAt); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(m.dir, key.id+".pem"))
+}
+
+func (m *KeyManager) load(removeExpired bool) error {
+	paths, err := filepath.Glob(filepath.Join(m.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	var keys []*signingKey
+	for _, path := range paths {
+		key, err := m.loadFile(path)
+		if err != nil {
+			// One bad file must not take down verification with every other key.
+			log.Printf("skipping signing key %s: %v", path, err)
+			continue
+		}
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].createdAt.Before(keys[j].createdAt) })
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(keys) > 0 {
+		m.keys = keys
+		m.prune(time.Now(), removeExpired)
+	}
+	return nil
+}
+
+// loadFile returns nil for keys of a different algorithm than the manager's.
+func (m *KeyManager) loadFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	var private crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if m.method != jwt.SigningMethodRS256 {
+			return nil, nil
+		}
+		private = key
+	case ed25519.PrivateKey:
+		if m.method != jwt.SigningMethodEdDSA {
+			return nil, nil
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return &signingKey{
+		id:        strings.TrimSuffix(filepath.Base(path), ".pem"),
+		private:   private,
+		createdAt: info.ModTime(),
+	}, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"time"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (int, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, string, error)
+	GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int, userID int) error
+	RevokeUserAPIKeys(ctx context.Context, userID int) error
+	TouchAPIKey(ctx context.Context, id int, now time.Time) error
+}
+
+type PostgresAPIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAPIKeyRepository(db *sql.DB) *PostgresAPIKeyRepository {
+	return &PostgresAPIKeyRepository{db: db}
+}
+
+func (r *PostgresAPIKeyRepository) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (int, error) {
+	var id int
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		key.UserID, key.Name, key.Prefix, keyHash, pq.Array(key.Scopes), key.ExpiresAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// GetAPIKeyByPrefix returns the key together with its stored hash.
+func (r *PostgresAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, string, error) {
+	key := &models.APIKey{}
+	var keyHash string
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at, key_hash
+		 FROM api_keys WHERE prefix = $1`, prefix,
+	).Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.ExpiresAt,
+		&key.LastUsedAt, &key.RevokedAt, &key.CreatedAt, &keyHash)
+	if err == sql.ErrNoRows {
+		return nil, "", errs.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return key, keyHash, nil
+}
+
+func (r *PostgresAPIKeyRepository) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at
+		 FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.ExpiresAt,
+			&key.LastUsedAt, &key.RevokedAt, &key.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *PostgresAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int, userID int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		id, userID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *PostgresAPIKeyRepository) RevokeUserAPIKeys(ctx context.Context, userID int) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
+
+// TouchAPIKey records use of the key, at most once a minute to keep busy bots
+// from writing on every request.
+func (r *PostgresAPIKeyRepository) TouchAPIKey(ctx context.Context, id int, now time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE api_keys SET last_used_at = $2 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)",
+		id, now, now.Add(-time.Minute))
+	return err
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type AuditRepository interface {
+	CreateAuditEntry(ctx context.Context, entry models.AuditEntry) error
+}
+
+type PostgresAuditRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresAuditRepository(db *sql.DB) *PostgresAuditRepository {
+	return &PostgresAuditRepository{db: db}
+}
+
+func (r *PostgresAuditRepository) CreateAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"INSERT INTO audit_log (event, user_id, username, ip, details) VALUES ($1, $2, $3, $4, $5)",
+		entry.Event, entry.UserID, entry.Username, entry.IP, entry.Details)
+	return err
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"auction/internal/errs"
 	"auction/internal/models"
 	"context"
 	"database/sql"
@@ -9,6 +10,11 @@ import (
 type BidRepository interface {
 	CreateBid(ctx context.Context, bid models.BidCreate) (int, error)
 	GetMyBids(ctx context.Context, userID int) ([]models.Bid, error)
+	GetHighestBid(ctx context.Context, lotID int) (*models.Bid, error)
+	GetTopBids(ctx context.Context, lotID int, limit int) ([]models.Bid, error)
+	GetUserBidForLot(ctx context.Context, lotID int, userID int) (*models.Bid, error)
+	UpdateBidAmount(ctx context.Context, bidID int, amount int) error
+	GetLotBidderIDs(ctx context.Context, lotID int) ([]int, error)
 }
 
 type PostgresBidRepository struct {
@@ -22,7 +28,7 @@ func NewPostgresBidRepository(db *sql.DB) *PostgresBidRepository {
 
 func (r *PostgresBidRepository) CreateBid(ctx context.Context, bid models.BidCreate) (int, error) {
 	var bidID int
-	err := r.db.QueryRowContext(ctx, "INSERT INTO bids (lot_id, user_id, amount) VALUES ($1, $2, $3) "+
+	err := conn(ctx, r.db).QueryRowContext(ctx, "INSERT INTO bids (lot_id, user_id, amount) VALUES ($1, $2, $3) "+
 		"RETURNING id", bid.LotID, bid.UserID, bid.Amount).Scan(&bidID)
 	if err != nil {
 		return 0, err
@@ -31,7 +37,7 @@ func (r *PostgresBidRepository) CreateBid(ctx context.Context, bid models.BidCre
 }
 
 func (r *PostgresBidRepository) GetMyBids(ctx context.Context, userID int) ([]models.Bid, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT id, lot_id, user_id, amount, created_at FROM bids WHERE user_id = $1", userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, "SELECT id, lot_id, user_id, amount, created_at FROM bids WHERE user_id = $1", userID)
 	if err != nil {
 		return nil, err
 	}
@@ -50,3 +56,84 @@ func (r *PostgresBidRepository) GetMyBids(ctx context.Context, userID int) ([]mo
 	}
 	return bids, nil
 }
+
+func (r *PostgresBidRepository) GetHighestBid(ctx context.Context, lotID int) (*models.Bid, error) {
+	bid := &models.Bid{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT id, lot_id, user_id, amount, created_at FROM bids WHERE lot_id = $1
+		 ORDER BY amount DESC, created_at ASC, id ASC LIMIT 1`, lotID).Scan(
+		&bid.ID, &bid.LotID, &bid.UserID, &bid.Amount, &bid.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNoBids
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bid, nil
+}
+
+func (r *PostgresBidRepository) GetTopBids(ctx context.Context, lotID int, limit int) ([]models.Bid, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, lot_id, user_id, amount, created_at FROM bids WHERE lot_id = $1
+		 ORDER BY amount DESC, created_at ASC, id ASC LIMIT $2`, lotID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var bids []models.Bid
+	for rows.Next() {
+		var bid models.Bid
+		err := rows.Scan(&bid.ID, &bid.LotID, &bid.UserID, &bid.Amount, &bid.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bids = append(bids, bid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return bids, nil
+}
+
+func (r *PostgresBidRepository) GetUserBidForLot(ctx context.Context, lotID int, userID int) (*models.Bid, error) {
+	bid := &models.Bid{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT id, lot_id, user_id, amount, created_at FROM bids WHERE lot_id = $1 AND user_id = $2
+		 ORDER BY created_at DESC, id DESC LIMIT 1`, lotID, userID).Scan(
+		&bid.ID, &bid.LotID, &bid.UserID, &bid.Amount, &bid.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrNoBids
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bid, nil
+}
+
+// UpdateBidAmount revises a bid; the revision time counts for tie-breaking.
+func (r *PostgresBidRepository) UpdateBidAmount(ctx context.Context, bidID int, amount int) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE bids SET amount = $1, created_at = CURRENT_TIMESTAMP WHERE id = $2", amount, bidID)
+	return err
+}
+
+func (r *PostgresBidRepository) GetLotBidderIDs(ctx context.Context, lotID int) ([]int, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		"SELECT DISTINCT user_id FROM bids WHERE lot_id = $1 ORDER BY user_id", lotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type LoginAttemptRepository interface {
+	RecordFailure(ctx context.Context, key string, now time.Time, window time.Duration) (int, time.Time, error)
+	LockUntil(ctx context.Context, key string, until time.Time) error
+	ReleaseAttempt(ctx context.Context, key string) error
+	ResetAttempts(ctx context.Context, key string) error
+}
+
+type PostgresLoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresLoginAttemptRepository(db *sql.DB) *PostgresLoginAttemptRepository {
+	return &PostgresLoginAttemptRepository{db: db}
+}
+
+// RecordFailure counts a failed attempt and returns the number of failures in
+// the current series together with the key's lock expiry, zero when it was
+// never locked. A series restarts when the previous failure is older than
+// window. The row stays locked until the surrounding transaction ends, so
+// concurrent attempts for the same key are counted one after another.
+func (r *PostgresLoginAttemptRepository) RecordFailure(ctx context.Context, key string, now time.Time,
+	window time.Duration) (int, time.Time, error) {
+	var failures int
+	var lockedUntil sql.NullTime
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, $2)
+		 ON CONFLICT (key) DO UPDATE SET
+		     failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1
+		                     ELSE login_attempts.failures + 1 END,
+		     last_failure_at = $2
+		 RETURNING failures, locked_until`, key, now, now.Add(-window)).Scan(&failures, &lockedUntil)
+	return failures, lockedUntil.Time, err
+}
+
+func (r *PostgresLoginAttemptRepository) LockUntil(ctx context.Context, key string, until time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE login_attempts SET locked_until = GREATEST(COALESCE(locked_until, $2), $2) WHERE key = $1",
+		key, until)
+	return err
+}
+
+// ReleaseAttempt takes back one counted failure for an attempt that succeeded
+// and lifts the lock that counting it may have applied. A lock in force when
+// the attempt was made would have refused it, and the next failure locks the
+// key again according to the remaining count.
+func (r *PostgresLoginAttemptRepository) ReleaseAttempt(ctx context.Context, key string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE login_attempts SET failures = GREATEST(failures - 1, 0), locked_until = NULL WHERE key = $1",
+		key)
+	return err
+}
+
+func (r *PostgresLoginAttemptRepository) ResetAttempts(ctx context.Context, key string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM login_attempts WHERE key = $1", key)
+	return err
+}
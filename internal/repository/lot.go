@@ -5,18 +5,23 @@ import (
 	"auction/internal/models"
 	"context"
 	"database/sql"
+	"time"
 )
 
 type LotRepository interface {
 	CreateLot(ctx context.Context, lot models.LotCreate) (int, error)
 	GetLots(ctx context.Context) ([]models.LotResponse, error)
 	GetLotByID(ctx context.Context, id int) (*models.LotResponse, error)
-	DeleteLot(ctx context.Context, id int) error
 	UpdateLotPrice(ctx context.Context, lotID int, newPrice int) error
-}
-
-type UserRepository interface {
-	GetUserRole(ctx context.Context, userID int) (string, error)
+	RaiseLotPrice(ctx context.Context, lotID int, newPrice int) error
+	ExtendLotEndTime(ctx context.Context, lotID int, extension time.Duration) (time.Time, error)
+	GetLotForUpdate(ctx context.Context, id int) (*models.LotResponse, error)
+	GetExpiredLotIDs(ctx context.Context, now time.Time) ([]int, error)
+	UpdateLotStatus(ctx context.Context, change models.LotStatusChange) error
+	GetStatusHistory(ctx context.Context, lotID int) ([]models.LotStatusChange, error)
+	UpdateLotDetails(ctx context.Context, lotID int, update models.LotUpdate) error
+	CreateLotRevision(ctx context.Context, revision models.LotRevision) error
+	GetLotRevisions(ctx context.Context, lotID int) ([]models.LotRevision, error)
 }
 
 type PostgresLotRepository struct {
@@ -28,20 +33,15 @@ func NewPostgresLotRepository(db *sql.DB) *PostgresLotRepository {
 	return &PostgresLotRepository{db: db}
 }
 
-type PostgresUserRepository struct {
-	db *sql.DB
-}
-
-func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
-	return &PostgresUserRepository{db: db}
-}
-
 func (r *PostgresLotRepository) CreateLot(ctx context.Context, lot models.LotCreate) (int, error) {
 	var lotID int
-	err := r.db.QueryRowContext(ctx,
-		`INSERT INTO lots (title, description, start_price, current_price, end_time, user_id, created_at) 
-		 VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		lot.Title, lot.Description, lot.StartPrice, lot.CurrentPrice, lot.EndTime, lot.UserID, lot.CreatedAt,
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`INSERT INTO lots (title, description, start_price, current_price, start_time, end_time, user_id, created_at,
+		 increment_schedule, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_seconds)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15) RETURNING id`,
+		lot.Title, lot.Description, lot.StartPrice, lot.CurrentPrice, lot.StartTime, lot.EndTime, lot.UserID,
+		lot.CreatedAt, lot.IncrementSchedule, lot.ReservePrice, lot.BuyNowPrice, lot.AuctionType, lot.FloorPrice,
+		lot.PriceDecrement, lot.DecrementSeconds,
 	).Scan(&lotID)
 
 	if err != nil {
@@ -52,8 +52,10 @@ func (r *PostgresLotRepository) CreateLot(ctx context.Context, lot models.LotCre
 }
 
 func (r *PostgresLotRepository) GetLots(ctx context.Context) ([]models.LotResponse, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, title, description, start_price, current_price, end_time, 
-       created_at, user_id FROM lots WHERE status = 'active' ORDER BY created_at DESC`)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `SELECT id, title, description, start_price, current_price, start_time, 
+       end_time, created_at, user_id, status, increment_schedule, extension_count, reserve_price, buy_now_price, auction_type,
+       floor_price, price_decrement, decrement_seconds FROM lots WHERE status = 'active'
+       ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
@@ -67,9 +69,19 @@ func (r *PostgresLotRepository) GetLots(ctx context.Context) ([]models.LotRespon
 			&lot.Description,
 			&lot.StartPrice,
 			&lot.CurrentPrice,
+			&lot.StartTime,
 			&lot.EndTime,
 			&lot.CreatedAt,
-			&lot.UserID)
+			&lot.UserID,
+			&lot.Status,
+			&lot.IncrementSchedule,
+			&lot.ExtensionCount,
+			&lot.ReservePrice,
+			&lot.BuyNowPrice,
+			&lot.AuctionType,
+			&lot.FloorPrice,
+			&lot.PriceDecrement,
+			&lot.DecrementSeconds)
 		if err != nil {
 			return nil, err
 		}
@@ -85,15 +97,43 @@ func (r *PostgresLotRepository) GetLotByID(ctx context.Context, id int) (*models
 	if id <= 0 {
 		return nil, errs.ErrFoundLot
 	}
-	query := `SELECT id, title, description, start_price, current_price, end_time FROM lots WHERE id = $1`
+	query := `SELECT id, title, description, start_price, current_price, start_time, end_time, created_at,
+		user_id, status, increment_schedule, extension_count, reserve_price, buy_now_price, auction_type,
+		floor_price, price_decrement, decrement_seconds FROM lots WHERE id = $1`
+	return r.getLot(ctx, query, id)
+}
+
+func (r *PostgresLotRepository) GetLotForUpdate(ctx context.Context, id int) (*models.LotResponse, error) {
+	if id <= 0 {
+		return nil, errs.ErrFoundLot
+	}
+	query := `SELECT id, title, description, start_price, current_price, start_time, end_time, created_at,
+		user_id, status, increment_schedule, extension_count, reserve_price, buy_now_price, auction_type,
+		floor_price, price_decrement, decrement_seconds FROM lots WHERE id = $1 FOR UPDATE`
+	return r.getLot(ctx, query, id)
+}
+
+func (r *PostgresLotRepository) getLot(ctx context.Context, query string, id int) (*models.LotResponse, error) {
 	lot := &models.LotResponse{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&lot.ID,
 		&lot.Title,
 		&lot.Description,
 		&lot.StartPrice,
 		&lot.CurrentPrice,
-		&lot.EndTime)
+		&lot.StartTime,
+		&lot.EndTime,
+		&lot.CreatedAt,
+		&lot.UserID,
+		&lot.Status,
+		&lot.IncrementSchedule,
+		&lot.ExtensionCount,
+		&lot.ReservePrice,
+		&lot.BuyNowPrice,
+		&lot.AuctionType,
+		&lot.FloorPrice,
+		&lot.PriceDecrement,
+		&lot.DecrementSeconds)
 	if err == sql.ErrNoRows {
 		return nil, errs.ErrFoundLot
 	}
@@ -104,8 +144,8 @@ func (r *PostgresLotRepository) GetLotByID(ctx context.Context, id int) (*models
 	return lot, nil
 }
 
-func (r *PostgresLotRepository) DeleteLot(ctx context.Context, id int) error {
-	result, err := r.db.ExecContext(ctx, `DELETE FROM lots WHERE id = $1`, id)
+func (r *PostgresLotRepository) UpdateLotPrice(ctx context.Context, lotID int, newPrice int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE lots SET current_price = $1 WHERE id = $2", newPrice, lotID)
 	if err != nil {
 		return err
 	}
@@ -116,28 +156,155 @@ func (r *PostgresLotRepository) DeleteLot(ctx context.Context, id int) error {
 	return nil
 }
 
-func (r *PostgresUserRepository) GetUserRole(ctx context.Context, userID int) (string, error) {
-	var role string
-	err := r.db.QueryRowContext(ctx,
-		"SELECT role FROM users WHERE id = $1",
-		userID,
-	).Scan(&role)
+// RaiseLotPrice only moves current_price upwards; it reports ErrBidConflict
+// when a concurrent bid has already reached newPrice.
+func (r *PostgresLotRepository) RaiseLotPrice(ctx context.Context, lotID int, newPrice int) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE lots SET current_price = $1 WHERE id = $2 AND current_price < $1", newPrice, lotID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrBidConflict
+	}
+	return nil
+}
 
+func (r *PostgresLotRepository) ExtendLotEndTime(ctx context.Context, lotID int,
+	extension time.Duration) (time.Time, error) {
+	var endTime time.Time
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE lots SET end_time = end_time + $1 * INTERVAL '1 second', extension_count = extension_count + 1
+		 WHERE id = $2 RETURNING end_time`, extension.Seconds(), lotID).Scan(&endTime)
+	if err == sql.ErrNoRows {
+		return time.Time{}, errs.ErrFoundLot
+	}
 	if err != nil {
-		return "", err
+		return time.Time{}, err
 	}
+	return endTime, nil
+}
 
-	return role, nil
+func (r *PostgresLotRepository) GetExpiredLotIDs(ctx context.Context, now time.Time) ([]int, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id FROM lots WHERE status = 'active' AND end_time <= $1 ORDER BY end_time`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return ids, nil
 }
 
-func (r *PostgresLotRepository) UpdateLotPrice(ctx context.Context, lotID int, newPrice int) error {
-	result, err := r.db.ExecContext(ctx, "UPDATE lots SET current_price = $1 WHERE id = $2", newPrice, lotID)
+// UpdateLotStatus moves the lot from change.FromStatus to change.ToStatus and
+// records the transition. It returns ErrFoundLot when the lot does not exist
+// or is no longer in FromStatus.
+func (r *PostgresLotRepository) UpdateLotStatus(ctx context.Context, change models.LotStatusChange) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`WITH updated AS (
+		     UPDATE lots SET status = $1 WHERE id = $2 AND status = $3 RETURNING id
+		 )
+		 INSERT INTO lot_status_history (lot_id, from_status, to_status, actor_id, reason)
+		 SELECT id, $3, $1, $4, $5 FROM updated`,
+		change.ToStatus, change.LotID, change.FromStatus, change.ActorID, change.Reason)
 	if err != nil {
 		return err
 	}
-	_, err = result.RowsAffected()
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrFoundLot
+	}
+	return nil
+}
+
+func (r *PostgresLotRepository) GetStatusHistory(ctx context.Context, lotID int) ([]models.LotStatusChange, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, lot_id, from_status, to_status, actor_id, reason, created_at FROM lot_status_history
+		 WHERE lot_id = $1 ORDER BY created_at, id`, lotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []models.LotStatusChange
+	for rows.Next() {
+		var change models.LotStatusChange
+		err := rows.Scan(&change.ID, &change.LotID, &change.FromStatus, &change.ToStatus, &change.ActorID,
+			&change.Reason, &change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (r *PostgresLotRepository) UpdateLotDetails(ctx context.Context, lotID int, update models.LotUpdate) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE lots SET title = $1, description = $2, start_price = $3, current_price = $4, end_time = $5
+		 WHERE id = $6`,
+		update.Title, update.Description, update.StartPrice, update.CurrentPrice, update.EndTime, lotID)
 	if err != nil {
 		return err
 	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrFoundLot
+	}
 	return nil
 }
+
+func (r *PostgresLotRepository) CreateLotRevision(ctx context.Context, revision models.LotRevision) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO lot_revisions (lot_id, editor_id, title, description, start_price, end_time)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		revision.LotID, revision.EditorID, revision.Title, revision.Description, revision.StartPrice,
+		revision.EndTime)
+	return err
+}
+
+func (r *PostgresLotRepository) GetLotRevisions(ctx context.Context, lotID int) ([]models.LotRevision, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, lot_id, editor_id, title, description, start_price, end_time, created_at FROM lot_revisions
+		 WHERE lot_id = $1 ORDER BY created_at, id`, lotID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var revisions []models.LotRevision
+	for rows.Next() {
+		var revision models.LotRevision
+		err := rows.Scan(&revision.ID, &revision.LotID, &revision.EditorID, &revision.Title,
+			&revision.Description, &revision.StartPrice, &revision.EndTime, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
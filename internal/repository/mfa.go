@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type MFARepository interface {
+	GetMFA(ctx context.Context, userID int) (*models.MFAState, error)
+	SetPendingSecret(ctx context.Context, userID int, secret string) error
+	EnableMFA(ctx context.Context, userID int, step int64) error
+	DisableMFA(ctx context.Context, userID int) error
+	UseTOTPStep(ctx context.Context, userID int, step int64) error
+	ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) error
+}
+
+type PostgresMFARepository struct {
+	db *sql.DB
+}
+
+func NewPostgresMFARepository(db *sql.DB) *PostgresMFARepository {
+	return &PostgresMFARepository{db: db}
+}
+
+func (r *PostgresMFARepository) GetMFA(ctx context.Context, userID int) (*models.MFAState, error) {
+	state := &models.MFAState{}
+	var secret sql.NullString
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled_at, totp_last_step FROM users WHERE id = $1", userID).Scan(
+		&secret, &state.EnabledAt, &state.LastStep)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	state.Secret = secret.String
+	return state, nil
+}
+
+// SetPendingSecret stores a secret awaiting confirmation. It fails with
+// ErrMFAAlreadyEnabled rather than replacing the secret of an enabled account.
+func (r *PostgresMFARepository) SetPendingSecret(ctx context.Context, userID int, secret string) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE users SET totp_secret = $2, totp_last_step = NULL WHERE id = $1 AND totp_enabled_at IS NULL",
+		userID, secret)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) EnableMFA(ctx context.Context, userID int, step int64) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE users SET totp_enabled_at = CURRENT_TIMESTAMP, totp_last_step = $2
+		 WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL`,
+		userID, step)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) DisableMFA(ctx context.Context, userID int) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1",
+		userID)
+	if err != nil {
+		return err
+	}
+	_, err = conn(ctx, r.db).ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	return err
+}
+
+// UseTOTPStep records step as the last accepted one. A step at or before the
+// last accepted one is a replayed code and yields ErrMFACodeInvalid.
+func (r *PostgresMFARepository) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE users SET totp_last_step = $2
+		 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`,
+		userID, step)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrMFACodeInvalid
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		_, err := conn(ctx, r.db).ExecContext(ctx,
+			"INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PostgresMFARepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		 WHERE id = (SELECT id FROM mfa_recovery_codes
+		             WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL LIMIT 1)
+		   AND used_at IS NULL`,
+		userID, codeHash)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrMFACodeInvalid
+	}
+	return nil
+}
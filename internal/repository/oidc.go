@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type OIDCRepository interface {
+	CreateLoginState(ctx context.Context, state models.OIDCLoginState) error
+	UseLoginState(ctx context.Context, state string, now time.Time) (*models.OIDCLoginState, error)
+	GetUserIDByIdentity(ctx context.Context, provider, subject string) (int, error)
+	CreateIdentity(ctx context.Context, identity models.UserIdentity) error
+}
+
+type PostgresOIDCRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresOIDCRepository(db *sql.DB) *PostgresOIDCRepository {
+	return &PostgresOIDCRepository{db: db}
+}
+
+func (r *PostgresOIDCRepository) CreateLoginState(ctx context.Context, state models.OIDCLoginState) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO oidc_login_states (state, provider, nonce, code_verifier, expires_at)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		state.State, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt)
+	return err
+}
+
+// UseLoginState deletes the state so a callback can only be redeemed once,
+// and clears out states abandoned before reaching the callback.
+func (r *PostgresOIDCRepository) UseLoginState(ctx context.Context, state string,
+	now time.Time) (*models.OIDCLoginState, error) {
+	loginState := &models.OIDCLoginState{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`DELETE FROM oidc_login_states WHERE state = $1
+		 RETURNING state, provider, nonce, code_verifier, expires_at`, state).Scan(
+		&loginState.State, &loginState.Provider, &loginState.Nonce, &loginState.CodeVerifier, &loginState.ExpiresAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if _, err := conn(ctx, r.db).ExecContext(ctx,
+		"DELETE FROM oidc_login_states WHERE expires_at <= $1", now); err != nil {
+		return nil, err
+	}
+	if err == sql.ErrNoRows || !loginState.ExpiresAt.After(now) {
+		return nil, errs.ErrOIDCStateInvalid
+	}
+	return loginState, nil
+}
+
+func (r *PostgresOIDCRepository) GetUserIDByIdentity(ctx context.Context, provider, subject string) (int, error) {
+	var userID int
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2",
+		provider, subject).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, errs.ErrIdentityNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *PostgresOIDCRepository) CreateIdentity(ctx context.Context, identity models.UserIdentity) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)",
+		identity.Provider, identity.Subject, identity.UserID, identity.Email)
+	return err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PasswordResetRepository stores reset tokens by their SHA-256 hash only, so
+// a leaked table cannot be used to reset passwords.
+type PasswordResetRepository interface {
+	CreateResetToken(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error
+	UseResetToken(ctx context.Context, tokenHash string, now time.Time) (int, error)
+	InvalidateUserTokens(ctx context.Context, userID int) error
+}
+
+type PostgresPasswordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresPasswordResetRepository(db *sql.DB) *PostgresPasswordResetRepository {
+	return &PostgresPasswordResetRepository{db: db}
+}
+
+func (r *PostgresPasswordResetRepository) CreateResetToken(ctx context.Context, tokenHash string, userID int,
+	expiresAt time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		tokenHash, userID, expiresAt)
+	return err
+}
+
+// UseResetToken marks an unused, unexpired token as used and returns its
+// user. Any other token yields ErrResetTokenInvalid.
+func (r *PostgresPasswordResetRepository) UseResetToken(ctx context.Context, tokenHash string,
+	now time.Time) (int, error) {
+	var userID int
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE password_reset_tokens SET used_at = $2
+		 WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		 RETURNING user_id`, tokenHash, now).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, errs.ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *PostgresPasswordResetRepository) InvalidateUserTokens(ctx context.Context, userID int) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL",
+		userID)
+	return err
+}
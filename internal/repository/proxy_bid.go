@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type ProxyBidRepository interface {
+	UpsertProxyBid(ctx context.Context, proxyBid models.ProxyBid) error
+	GetProxyBid(ctx context.Context, lotID int, userID int) (*models.ProxyBid, error)
+}
+
+type PostgresProxyBidRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresProxyBidRepository(db *sql.DB) *PostgresProxyBidRepository {
+	return &PostgresProxyBidRepository{db: db}
+}
+
+// UpsertProxyBid stores the bidder's maximum; a lower maximum never replaces a higher one.
+func (r *PostgresProxyBidRepository) UpsertProxyBid(ctx context.Context, proxyBid models.ProxyBid) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		`INSERT INTO proxy_bids (lot_id, user_id, max_amount) VALUES ($1, $2, $3)
+		 ON CONFLICT (lot_id, user_id) DO UPDATE
+		 SET max_amount = EXCLUDED.max_amount, updated_at = CURRENT_TIMESTAMP
+		 WHERE proxy_bids.max_amount < EXCLUDED.max_amount`,
+		proxyBid.LotID, proxyBid.UserID, proxyBid.MaxAmount)
+	return err
+}
+
+func (r *PostgresProxyBidRepository) GetProxyBid(ctx context.Context, lotID int, userID int) (*models.ProxyBid, error) {
+	proxyBid := &models.ProxyBid{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT lot_id, user_id, max_amount, updated_at FROM proxy_bids WHERE lot_id = $1 AND user_id = $2",
+		lotID, userID).Scan(&proxyBid.LotID, &proxyBid.UserID, &proxyBid.MaxAmount, &proxyBid.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrProxyBidNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return proxyBid, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token models.RefreshToken) error
+	UseRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeUserTokens(ctx context.Context, userID int) error
+}
+
+type PostgresRefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRefreshTokenRepository(db *sql.DB) *PostgresRefreshTokenRepository {
+	return &PostgresRefreshTokenRepository{db: db}
+}
+
+func (r *PostgresRefreshTokenRepository) CreateRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"INSERT INTO refresh_tokens (id, family_id, user_id, expires_at) VALUES ($1, $2, $3, $4)",
+		token.ID, token.FamilyID, token.UserID, token.ExpiresAt)
+	return err
+}
+
+// UseRefreshToken marks the token as used exactly once. A token that exists
+// but was already used or revoked yields ErrRefreshTokenReused.
+func (r *PostgresRefreshTokenRepository) UseRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP
+		 WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL
+		 RETURNING id, family_id, user_id, expires_at, created_at`, id).Scan(
+		&token.ID, &token.FamilyID, &token.UserID, &token.ExpiresAt, &token.CreatedAt)
+	if err == nil {
+		return token, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	var exists bool
+	err = conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM refresh_tokens WHERE id = $1)", id).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errs.ErrRefreshTokenNotFound
+	}
+	return nil, errs.ErrRefreshTokenReused
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID)
+	return err
+}
+
+func (r *PostgresRefreshTokenRepository) RevokeUserTokens(ctx context.Context, userID int) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL",
+		userID)
+	return err
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type RoleRepository interface {
+	HasPermission(ctx context.Context, userID int, permission string) (bool, error)
+	GetRoles(ctx context.Context) ([]models.Role, error)
+	RoleExists(ctx context.Context, name string) (bool, error)
+}
+
+type PostgresRoleRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresRoleRepository(db *sql.DB) *PostgresRoleRepository {
+	return &PostgresRoleRepository{db: db}
+}
+
+// HasPermission checks the user's current role, so role changes apply to
+// tokens that were issued before them.
+func (r *PostgresRoleRepository) HasPermission(ctx context.Context, userID int, permission string) (bool, error) {
+	var allowed bool
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT EXISTS (
+		     SELECT 1 FROM users u
+		     JOIN role_permissions rp ON rp.role = u.role
+		     WHERE u.id = $1 AND rp.permission = $2)`,
+		userID, permission,
+	).Scan(&allowed)
+	return allowed, err
+}
+
+func (r *PostgresRoleRepository) GetRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx,
+		`SELECT r.name, r.description, rp.permission FROM roles r
+		 LEFT JOIN role_permissions rp ON rp.role = r.name
+		 ORDER BY r.name, rp.permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		var name, description string
+		var permission sql.NullString
+		if err := rows.Scan(&name, &description, &permission); err != nil {
+			return nil, err
+		}
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, models.Role{Name: name, Description: description, Permissions: []string{}})
+		}
+		if permission.Valid {
+			last := &roles[len(roles)-1]
+			last.Permissions = append(last.Permissions, permission.String)
+		}
+	}
+	return roles, rows.Err()
+}
+
+func (r *PostgresRoleRepository) RoleExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM roles WHERE name = $1)", name).Scan(&exists)
+	return exists, err
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type UserRepository interface {
+	CreateUser(ctx context.Context, user models.User) (int, error)
+	GetUserByID(ctx context.Context, id int) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	UpdatePassword(ctx context.Context, userID int, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, userID int, email string) error
+	UpdateUserRole(ctx context.Context, userID int, role string) error
+	IsEmailVerified(ctx context.Context, userID int) (bool, error)
+}
+
+type PostgresUserRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresUserRepository(db *sql.DB) *PostgresUserRepository {
+	return &PostgresUserRepository{db: db}
+}
+
+// CreateUser stores user with user.Password as the already hashed password.
+func (r *PostgresUserRepository) CreateUser(ctx context.Context, user models.User) (int, error) {
+	var userID int
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"INSERT INTO users (username, email, password_hash, role) VALUES ($1, $2, $3, $4) RETURNING id",
+		user.Username, user.Email, user.Password, user.Role,
+	).Scan(&userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+func (r *PostgresUserRepository) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	return r.getUser(ctx, "id = $1", id)
+}
+
+func (r *PostgresUserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.getUser(ctx, "username = $1", username)
+}
+
+func (r *PostgresUserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.getUser(ctx, "email = $1", email)
+}
+
+func (r *PostgresUserRepository) getUser(ctx context.Context, where string, arg interface{}) (*models.User, error) {
+	user := &models.User{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT id, username, email, password_hash, role,
+		 email_verified_at IS NOT NULL, totp_enabled_at IS NOT NULL
+		 FROM users WHERE `+where, arg,
+	).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.Password,
+		&user.Role,
+		&user.EmailVerified,
+		&user.MFAEnabled,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r *PostgresUserRepository) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	return r.updateUser(ctx, "UPDATE users SET password_hash = $2 WHERE id = $1", userID, passwordHash)
+}
+
+// MarkEmailVerified verifies email only while it is still the user's address.
+// It returns ErrEmailAlreadyVerified when there is nothing left to verify.
+func (r *PostgresUserRepository) MarkEmailVerified(ctx context.Context, userID int, email string) error {
+	err := r.updateUser(ctx,
+		"UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = $1 AND email = $2 AND email_verified_at IS NULL",
+		userID, email)
+	if err == errs.ErrUserNotFound {
+		return errs.ErrEmailAlreadyVerified
+	}
+	return err
+}
+
+func (r *PostgresUserRepository) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	return r.updateUser(ctx, "UPDATE users SET role = $2 WHERE id = $1", userID, role)
+}
+
+func (r *PostgresUserRepository) IsEmailVerified(ctx context.Context, userID int) (bool, error) {
+	var verified bool
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1",
+		userID,
+	).Scan(&verified)
+
+	if err != nil {
+		return false, err
+	}
+
+	return verified, nil
+}
+
+func (r *PostgresUserRepository) updateUser(ctx context.Context, query string, args ...interface{}) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errs.ErrUserNotFound
+	}
+	return nil
+}
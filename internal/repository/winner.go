@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"database/sql"
+)
+
+type WinnerRepository interface {
+	CreateWinner(ctx context.Context, winner models.Winner) error
+	GetWinnerByLotID(ctx context.Context, lotID int) (*models.Winner, error)
+}
+
+type PostgresWinnerRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresWinnerRepository(db *sql.DB) *PostgresWinnerRepository {
+	return &PostgresWinnerRepository{db: db}
+}
+
+func (r *PostgresWinnerRepository) CreateWinner(ctx context.Context, winner models.Winner) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx,
+		"INSERT INTO winners (lot_id, user_id, amount, win_date) VALUES ($1, $2, $3, $4)",
+		winner.LotID, winner.UserID, winner.Amount, winner.WinDate)
+	return err
+}
+
+func (r *PostgresWinnerRepository) GetWinnerByLotID(ctx context.Context, lotID int) (*models.Winner, error) {
+	winner := &models.Winner{}
+	err := conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT lot_id, user_id, amount, win_date FROM winners WHERE lot_id = $1", lotID).Scan(
+		&winner.LotID, &winner.UserID, &winner.Amount, &winner.WinDate)
+	if err == sql.ErrNoRows {
+		return nil, errs.ErrWinnerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return winner, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"auction/internal/pkg"
+	"auction/internal/repository"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+// API keys look like ak_<prefix>_<secret>. The prefix finds the row; only a
+// SHA-256 hash of the whole key is stored.
+const apiKeyScheme = "ak"
+
+// Keys are issued for defaultAPIKeyTTL unless the request sets an expiry, which
+// may be at most maxAPIKeyTTL away. No key lives forever.
+const (
+	defaultAPIKeyTTL = 90 * 24 * time.Hour
+	maxAPIKeyTTL     = 365 * 24 * time.Hour
+)
+
+type APIKeyService struct {
+	apiKeys  repository.APIKeyRepository
+	roleRepo repository.RoleRepository
+	userRepo repository.UserRepository
+}
+
+func NewAPIKeyService(apiKeys repository.APIKeyRepository, roleRepo repository.RoleRepository,
+	userRepo repository.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeys:  apiKeys,
+		roleRepo: roleRepo,
+		userRepo: userRepo,
+	}
+}
+
+// CreateAPIKey issues a key limited to scopes, each of which the user's role
+// must currently grant. Without an expiry the key expires after
+// defaultAPIKeyTTL.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID int,
+	req models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, errs.ErrInvalidAPIKeyName
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errs.ErrInvalidScope
+	}
+	for _, scope := range req.Scopes {
+		allowed, err := s.roleRepo.HasPermission(ctx, userID, scope)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errs.ErrInvalidScope
+		}
+	}
+	now := time.Now()
+	expiresAt := now.Add(defaultAPIKeyTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) || expiresAt.After(now.Add(maxAPIKeyTTL)) {
+		return nil, errs.ErrInvalidExpiry
+	}
+
+	prefix := pkg.NewTokenID()[:12]
+	secret := apiKeyScheme + "_" + prefix + "_" + pkg.NewTokenID()
+	key := models.APIKey{
+		UserID:    userID,
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		Scopes:    req.Scopes,
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+	}
+	id, err := s.apiKeys.CreateAPIKey(ctx, key, hashAPIKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	key.ID = id
+	return &models.CreateAPIKeyResponse{APIKey: key, Key: secret}, nil
+}
+
+func (s *APIKeyService) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	return s.apiKeys.GetAPIKeys(ctx, userID)
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID int, id int) error {
+	return s.apiKeys.RevokeAPIKey(ctx, id, userID)
+}
+
+// AuthenticateAPIKey resolves a raw key to its owner. Unknown, revoked and
+// expired keys all yield ErrInvalidAPIKey.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, raw string) (*models.User, *models.APIKey, error) {
+	parts := strings.Split(raw, "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme {
+		return nil, nil, errs.ErrInvalidAPIKey
+	}
+	key, keyHash, err := s.apiKeys.GetAPIKeyByPrefix(ctx, parts[1])
+	if err == errs.ErrAPIKeyNotFound {
+		return nil, nil, errs.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(raw)), []byte(keyHash)) != 1 {
+		return nil, nil, errs.ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.RevokedAt != nil || key.ExpiresAt == nil || !now.Before(*key.ExpiresAt) {
+		return nil, nil, errs.ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, key.UserID)
+	if err == errs.ErrUserNotFound {
+		return nil, nil, errs.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.apiKeys.TouchAPIKey(ctx, key.ID, now); err != nil {
+		log.Printf("error updating api key %d last use: %v", key.ID, err)
+	}
+	return user, key, nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyServiceCreateAPIKeyExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		// expiresIn sets the requested expiry; zero requests none.
+		expiresIn time.Duration
+		wantErr   error
+		wantTTL   time.Duration
+	}{
+		{name: "default expiry", wantTTL: defaultAPIKeyTTL},
+		{name: "explicit expiry", expiresIn: 7 * 24 * time.Hour, wantTTL: 7 * 24 * time.Hour},
+		{name: "maximum expiry", expiresIn: maxAPIKeyTTL - time.Minute, wantTTL: maxAPIKeyTTL - time.Minute},
+		{name: "past expiry", expiresIn: -time.Minute, wantErr: errs.ErrInvalidExpiry},
+		{name: "expiry beyond the maximum", expiresIn: maxAPIKeyTTL + time.Hour, wantErr: errs.ErrInvalidExpiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKeys := &fakeAPIKeyRepo{}
+			service := NewAPIKeyService(apiKeys, &fakeRoleRepo{permissions: []string{models.PermBidPlace}},
+				newFakeUserRepo())
+			req := models.CreateAPIKeyRequest{Name: "bot", Scopes: []string{models.PermBidPlace}}
+			if tt.expiresIn != 0 {
+				expiresAt := time.Now().Add(tt.expiresIn)
+				req.ExpiresAt = &expiresAt
+			}
+
+			start := time.Now()
+			response, err := service.CreateAPIKey(context.Background(), 1, req)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				if len(apiKeys.createdKeys) != 0 {
+					t.Fatalf("a rejected key was stored")
+				}
+				return
+			}
+			expiresAt := apiKeys.createdKeys[0].ExpiresAt
+			if expiresAt == nil || response.ExpiresAt == nil || !response.ExpiresAt.Equal(*expiresAt) {
+				t.Fatalf("want the stored and returned key to expire, got %v and %v", expiresAt,
+					response.ExpiresAt)
+			}
+			if ttl := expiresAt.Sub(start); ttl < tt.wantTTL-time.Second || ttl > tt.wantTTL+time.Second {
+				t.Fatalf("want the key to expire in %s, got %s", tt.wantTTL, ttl)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/mail"
+	"auction/internal/models"
+	"auction/internal/pkg"
+	"auction/internal/repository"
+	"auction/internal/utils"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const passwordResetTTL = time.Hour
+
+var dummyPasswordHash, _ = utils.HashPassword("dummy password for unknown users")
+
+// LoginResult holds either a token pair or, for accounts with two-factor
+// authentication, the challenge token for the second step. RetryAfter is set
+// together with ErrTooManyAttempts.
+type LoginResult struct {
+	Tokens     *models.AuthResponse
+	MFAToken   string
+	RetryAfter time.Duration
+}
+
+type AuthService struct {
+	userRepo    repository.UserRepository
+	refreshRepo repository.RefreshTokenRepository
+	revocations repository.TokenRevocationRepository
+	resetRepo   repository.PasswordResetRepository
+	mfaRepo     repository.MFARepository
+	apiKeys     repository.APIKeyRepository
+	tx          repository.Transactor
+	loginGuard  *LoginGuard
+	mailer      mail.Sender
+	baseURL     string
+	resetURL    string
+}
+
+func NewAuthService(userRepo repository.UserRepository, refreshRepo repository.RefreshTokenRepository,
+	revocations repository.TokenRevocationRepository, resetRepo repository.PasswordResetRepository,
+	mfaRepo repository.MFARepository, apiKeys repository.APIKeyRepository, tx repository.Transactor,
+	loginGuard *LoginGuard,
+	mailer mail.Sender, baseURL, resetURL string) *AuthService {
+	return &AuthService{
+		userRepo:    userRepo,
+		refreshRepo: refreshRepo,
+		revocations: revocations,
+		resetRepo:   resetRepo,
+		mfaRepo:     mfaRepo,
+		apiKeys:     apiKeys,
+		tx:          tx,
+		loginGuard:  loginGuard,
+		mailer:      mailer,
+		baseURL:     baseURL,
+		resetURL:    resetURL,
+	}
+}
+
+func (s *AuthService) Register(ctx context.Context, req models.RegisterRequest) (*models.AuthResponse, error) {
+	if len(req.Username) < 3 {
+		return nil, errs.ErrInvalidUsername
+	}
+	if len(req.Password) < 8 {
+		return nil, errs.ErrInvalidPassword
+	}
+	if err := s.checkAvailable(ctx, req.Username, req.Email); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: hashedPassword,
+		Role:     models.RoleUser,
+	}
+	user.ID, err = s.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sendVerificationEmail(ctx, user); err != nil {
+		log.Printf("error sending verification email to user %d: %v", user.ID, err)
+	}
+
+	return s.issueTokens(ctx, user, "")
+}
+
+func (s *AuthService) checkAvailable(ctx context.Context, username, email string) error {
+	_, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err == nil {
+		return errs.ErrEmailAlreadyExists
+	}
+	if err != errs.ErrUserNotFound {
+		return err
+	}
+	_, err = s.userRepo.GetUserByUsername(ctx, username)
+	if err == nil {
+		return errs.ErrUsernameAlreadyExists
+	}
+	if err != errs.ErrUserNotFound {
+		return err
+	}
+	return nil
+}
+
+// Login checks the password and returns a token pair, or a challenge token
+// when the account has two-factor authentication enabled.
+func (s *AuthService) Login(ctx context.Context, username, password, ip string) (*LoginResult, error) {
+	if result, err := s.reserveLoginAttempt(ctx, username, ip); err != nil {
+		return result, err
+	}
+
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil && err != errs.ErrUserNotFound {
+		return nil, err
+	}
+	hashedPassword := dummyPasswordHash
+	if user != nil {
+		hashedPassword = user.Password
+	}
+	// An unknown username costs a hash check too, so the response time does
+	// not reveal whether it exists.
+	// The attempt was already counted as failed by reserveLoginAttempt.
+	if !utils.CheckPasswordHash(password, hashedPassword) || user == nil {
+		return nil, errs.ErrInvalidCredentials
+	}
+
+	if utils.NeedsRehash(hashedPassword) {
+		s.rehashPassword(ctx, user.ID, password)
+	}
+
+	if user.MFAEnabled {
+		mfaToken, err := s.issueMFAChallenge(ctx, *user)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.loginGuard.Release(ctx, username, ip); err != nil {
+			log.Printf("error releasing login attempt: %v", err)
+		}
+		return &LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	s.recordLoginSuccess(ctx, username, ip)
+	tokens, err := s.issueTokens(ctx, *user, "")
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already used
+// revokes its whole family.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.AuthResponse, error) {
+	claims, err := pkg.ValidateToken(refreshToken, pkg.TokenTypeRefresh)
+	if err != nil || claims.FamilyID == "" {
+		return nil, errs.ErrInvalidToken
+	}
+
+	_, err = s.refreshRepo.UseRefreshToken(ctx, claims.ID)
+	switch err {
+	case nil:
+	case errs.ErrRefreshTokenReused:
+		log.Printf("refresh token reuse detected for user %d, revoking family %s", claims.UserID, claims.FamilyID)
+		if err := s.refreshRepo.RevokeFamily(ctx, claims.FamilyID); err != nil {
+			log.Printf("error revoking token family: %v", err)
+		}
+		return nil, errs.ErrInvalidToken
+	case errs.ErrRefreshTokenNotFound:
+		return nil, errs.ErrInvalidToken
+	default:
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err == errs.ErrUserNotFound {
+		return nil, errs.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(ctx, *user, claims.FamilyID)
+}
+
+// Logout revokes the access token in claims and, when refreshToken belongs to
+// the same user, its rotation family.
+func (s *AuthService) Logout(ctx context.Context, claims *pkg.CustomClaims, refreshToken string) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		if err := s.revocations.RevokeToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken != "" {
+		refreshClaims, err := pkg.ValidateToken(refreshToken, pkg.TokenTypeRefresh)
+		if err == nil && refreshClaims.UserID == claims.UserID && refreshClaims.FamilyID != "" {
+			if err := s.refreshRepo.RevokeFamily(ctx, refreshClaims.FamilyID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.revocations.DeleteExpired(ctx, time.Now()); err != nil {
+		log.Printf("error deleting expired revoked tokens: %v", err)
+	}
+	return nil
+}
+
+// RevokeAllSessions invalidates every access and refresh token issued to the
+// user so far, and their API keys, which a stolen session could have minted.
+func (s *AuthService) RevokeAllSessions(ctx context.Context, userID int) error {
+	if err := s.revocations.RevokeAllForUser(ctx, userID, time.Now()); err != nil {
+		return err
+	}
+	if err := s.refreshRepo.RevokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+	return s.apiKeys.RevokeUserAPIKeys(ctx, userID)
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	claims, err := pkg.ValidateToken(token, pkg.TokenTypeEmailVerification)
+	if err != nil {
+		return errs.ErrInvalidToken
+	}
+	return s.userRepo.MarkEmailVerified(ctx, claims.UserID, claims.Email)
+}
+
+func (s *AuthService) ResendVerification(ctx context.Context, userID int) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return errs.ErrEmailAlreadyVerified
+	}
+	return s.sendVerificationEmail(ctx, *user)
+}
+
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user models.User) error {
+	token, err := pkg.GenerateToken(user.ID, user.Username, user.Email, user.Role, pkg.TokenTypeEmailVerification, 0)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/api/verify-email?token=%s", s.baseURL, url.QueryEscape(token))
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf("Hello, %s!\n\nConfirm your email by opening this link:\n%s\n\n"+
+			"The link is valid for 24 hours.", user.Username, link),
+	})
+}
+
+// ForgotPassword mails a reset link when email belongs to a user. Unknown
+// addresses are not reported, so callers cannot probe for accounts.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err == errs.ErrUserNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token := pkg.NewTokenID()
+	expiresAt := time.Now().Add(passwordResetTTL)
+	if err := s.resetRepo.CreateResetToken(ctx, hashResetToken(token), user.ID, expiresAt); err != nil {
+		return err
+	}
+	sep := "?"
+	if strings.Contains(s.resetURL, "?") {
+		sep = "&"
+	}
+	link := s.resetURL + sep + "token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello, %s!\n\nTo set a new password, open this link:\n%s\n\n"+
+			"The link is valid for one hour and can be used once. "+
+			"If you did not request a reset, ignore this email.", user.Username, link),
+	})
+}
+
+// ResetPassword sets a new password using a reset token and ends all of the
+// user's sessions.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) error {
+	if len(password) < 8 {
+		return errs.ErrInvalidPassword
+	}
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	var userID int
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		userID, err = s.resetRepo.UseResetToken(ctx, hashResetToken(token), time.Now())
+		if err != nil {
+			return err
+		}
+		if err := s.userRepo.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+			return err
+		}
+		// Following the emailed link proves ownership of the address as well.
+		user, err := s.userRepo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		err = s.userRepo.MarkEmailVerified(ctx, userID, user.Email)
+		if err != nil && err != errs.ErrEmailAlreadyVerified {
+			return err
+		}
+		return s.resetRepo.InvalidateUserTokens(ctx, userID)
+	})
+	if err != nil {
+		return err
+	}
+	return s.RevokeAllSessions(ctx, userID)
+}
+
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// reserveLoginAttempt counts the attempt as failed up front, or returns
+// ErrTooManyAttempts with the wait when the username or address is locked.
+func (s *AuthService) reserveLoginAttempt(ctx context.Context, username, ip string) (*LoginResult, error) {
+	wait, err := s.loginGuard.Reserve(ctx, username, ip, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if wait > 0 {
+		return &LoginResult{RetryAfter: wait}, errs.ErrTooManyAttempts
+	}
+	return nil, nil
+}
+
+func (s *AuthService) recordLoginSuccess(ctx context.Context, username, ip string) {
+	if err := s.loginGuard.RecordSuccess(ctx, username, ip); err != nil {
+		log.Printf("error resetting login attempts: %v", err)
+	}
+}
+
+func (s *AuthService) rehashPassword(ctx context.Context, userID int, password string) {
+	newHash, err := utils.HashPassword(password)
+	if err != nil {
+		log.Printf("error rehashing password for user %d: %v", userID, err)
+		return
+	}
+	if err := s.userRepo.UpdatePassword(ctx, userID, newHash); err != nil {
+		log.Printf("error storing rehashed password for user %d: %v", userID, err)
+	}
+}
+
+// issueMFAChallenge signs the token that carries a login to the second step.
+func (s *AuthService) issueMFAChallenge(ctx context.Context, user models.User) (string, error) {
+	version, err := s.revocations.GetTokenVersion(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return pkg.GenerateToken(user.ID, user.Username, user.Email, user.Role, pkg.TokenTypeMFAChallenge, version)
+}
+
+// issueTokens issues an access/refresh pair and records the refresh token.
+// An empty familyID starts a new rotation chain.
+func (s *AuthService) issueTokens(ctx context.Context, user models.User, familyID string) (*models.AuthResponse, error) {
+	version, err := s.revocations.GetTokenVersion(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, err := pkg.GenerateToken(user.ID, user.Username, user.Email, user.Role, pkg.TokenTypeAccess, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID = pkg.NewTokenID()
+	}
+	refreshToken, claims, err := pkg.GenerateTokenInFamily(user.ID, user.Username, user.Email, user.Role,
+		pkg.TokenTypeRefresh, familyID, version)
+	if err != nil {
+		return nil, err
+	}
+	err = s.refreshRepo.CreateRefreshToken(ctx, models.RefreshToken{
+		ID:        claims.ID,
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
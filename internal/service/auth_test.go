@@ -0,0 +1,396 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"auction/internal/pkg"
+	"auction/internal/utils"
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+const testPassword = "correct horse battery"
+
+func TestAuthServiceLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(t *testing.T, f *authFixture)
+		username string
+		password string
+		wantErr  error
+		check    func(t *testing.T, f *authFixture, result *LoginResult)
+	}{
+		{
+			name:     "unknown user",
+			username: "nobody",
+			password: testPassword,
+			wantErr:  errs.ErrInvalidCredentials,
+		},
+		{
+			name: "wrong password",
+			setup: func(t *testing.T, f *authFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+			},
+			username: "alice",
+			password: "wrong password",
+			wantErr:  errs.ErrInvalidCredentials,
+		},
+		{
+			name: "throttled",
+			setup: func(t *testing.T, f *authFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+				f.attempts.lock(userKey("alice"), time.Now().Add(time.Minute))
+			},
+			username: "alice",
+			password: testPassword,
+			wantErr:  errs.ErrTooManyAttempts,
+			check: func(t *testing.T, f *authFixture, result *LoginResult) {
+				if result == nil || result.RetryAfter <= 0 {
+					t.Fatalf("want RetryAfter > 0, got %+v", result)
+				}
+			},
+		},
+		{
+			name: "throttled after repeated failures",
+			setup: func(t *testing.T, f *authFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+				for i := 0; i <= DefaultUserLoginPolicy.FreeAttempts; i++ {
+					_, err := f.service.Login(context.Background(), "alice", "wrong password", "10.0.0.1")
+					if err != errs.ErrInvalidCredentials {
+						t.Fatalf("attempt %d: want ErrInvalidCredentials, got %v", i+1, err)
+					}
+				}
+			},
+			username: "alice",
+			password: testPassword,
+			wantErr:  errs.ErrTooManyAttempts,
+		},
+		{
+			name: "mfa challenge",
+			setup: func(t *testing.T, f *authFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com", MFAEnabled: true},
+					testPassword)
+			},
+			username: "alice",
+			password: testPassword,
+			check: func(t *testing.T, f *authFixture, result *LoginResult) {
+				if result.MFAToken == "" || result.Tokens != nil {
+					t.Fatalf("want only an MFA token, got %+v", result)
+				}
+				if len(f.refresh.tokens) != 0 {
+					t.Fatalf("no refresh token may be issued before the second factor")
+				}
+			},
+		},
+		{
+			name: "success",
+			setup: func(t *testing.T, f *authFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+			},
+			username: "alice",
+			password: testPassword,
+			check: func(t *testing.T, f *authFixture, result *LoginResult) {
+				if result.Tokens == nil || result.Tokens.AccessToken == "" || result.Tokens.RefreshToken == "" {
+					t.Fatalf("want a token pair, got %+v", result)
+				}
+				if _, ok := f.attempts.attempts[userKey("alice")]; ok {
+					t.Fatalf("a successful login must reset the user's failures")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			result, err := f.service.Login(context.Background(), tt.username, tt.password, "10.0.0.1")
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if tt.check != nil {
+				tt.check(t, f, result)
+			}
+		})
+	}
+}
+
+func TestAuthServiceLoginAfterFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		mfa  bool
+		// fail makes the failed attempts that precede the correct logins.
+		fail func(t *testing.T, f *authFixture)
+	}{
+		{
+			name: "username failures",
+			fail: failLogins("alice", DefaultUserLoginPolicy.FreeAttempts),
+		},
+		{
+			name: "username failures before an mfa challenge",
+			mfa:  true,
+			fail: failLogins("alice", DefaultUserLoginPolicy.FreeAttempts),
+		},
+		{
+			name: "address failures",
+			fail: func(t *testing.T, f *authFixture) {
+				for i := 0; i < DefaultIPLoginPolicy.FreeAttempts; i++ {
+					failLogins(fmt.Sprintf("nobody%d", i), 1)(t, f)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuthFixture(t)
+			f.addUser(t, models.User{Username: "alice", Email: "alice@example.com", MFAEnabled: tt.mfa},
+				testPassword)
+			tt.fail(t, f)
+
+			// The first correct login is counted as the next failure until the
+			// password is checked; settling it must lift the delay that applied.
+			for i := 1; i <= 2; i++ {
+				result, err := f.service.Login(context.Background(), "alice", testPassword, "10.0.0.1")
+				if err != nil {
+					t.Fatalf("correct login %d: %v", i, err)
+				}
+				if result.Tokens == nil && result.MFAToken == "" {
+					t.Fatalf("correct login %d: want tokens or an MFA token, got %+v", i, result)
+				}
+			}
+		})
+	}
+}
+
+// failLogins returns a setup making n failed logins for username.
+func failLogins(username string, n int) func(t *testing.T, f *authFixture) {
+	return func(t *testing.T, f *authFixture) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			_, err := f.service.Login(context.Background(), username, "wrong password", "10.0.0.1")
+			if err != errs.ErrInvalidCredentials {
+				t.Fatalf("failed login %d for %s: want ErrInvalidCredentials, got %v", i+1, username, err)
+			}
+		}
+	}
+}
+
+func TestAuthServiceRefreshReuse(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+
+	login, err := f.service.Login(ctx, "alice", testPassword, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	original := login.Tokens.RefreshToken
+
+	rotated, err := f.service.Refresh(ctx, original)
+	if err != nil {
+		t.Fatalf("first refresh: %v", err)
+	}
+
+	if _, err := f.service.Refresh(ctx, original); err != errs.ErrInvalidToken {
+		t.Fatalf("reusing a refresh token: want ErrInvalidToken, got %v", err)
+	}
+	for id, token := range f.refresh.tokens {
+		if token.RevokedAt == nil {
+			t.Fatalf("token %s of the reused family is still active", id)
+		}
+	}
+	if _, err := f.service.Refresh(ctx, rotated.RefreshToken); err != errs.ErrInvalidToken {
+		t.Fatalf("refresh after reuse: want ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestAuthServiceRevokeAllSessionsSameSecond(t *testing.T) {
+	ctx := context.Background()
+	f := newAuthFixture(t)
+	userID := f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+
+	isRevoked := func(accessToken string) bool {
+		t.Helper()
+		claims, err := pkg.ValidateToken(accessToken, pkg.TokenTypeAccess)
+		if err != nil {
+			t.Fatalf("validating token: %v", err)
+		}
+		revoked, err := f.revocations.IsTokenRevoked(ctx, claims.ID, claims.UserID, claims.IssuedAt.Time,
+			claims.TokenVersion)
+		if err != nil {
+			t.Fatalf("checking revocation: %v", err)
+		}
+		return revoked
+	}
+
+	// Revoke and reissue within the same second as the first token, so the
+	// iat claims of all three tokens are equal.
+	var before, after *models.AuthResponse
+	for {
+		start := time.Now()
+		var err error
+		before, err = f.service.issueTokens(ctx, models.User{ID: userID, Username: "alice"}, "")
+		if err != nil {
+			t.Fatalf("issuing tokens: %v", err)
+		}
+		if err := f.service.RevokeAllSessions(ctx, userID); err != nil {
+			t.Fatalf("revoking sessions: %v", err)
+		}
+		after, err = f.service.issueTokens(ctx, models.User{ID: userID, Username: "alice"}, "")
+		if err != nil {
+			t.Fatalf("issuing tokens: %v", err)
+		}
+		if time.Now().Truncate(time.Second).Equal(start.Truncate(time.Second)) {
+			break
+		}
+	}
+
+	if !isRevoked(before.AccessToken) {
+		t.Fatalf("a token issued earlier in the second of the revocation is still valid")
+	}
+	if isRevoked(after.AccessToken) {
+		t.Fatalf("a token issued after the revocation is rejected")
+	}
+}
+
+func TestAuthServiceRefreshInvalidToken(t *testing.T) {
+	f := newAuthFixture(t)
+	if _, err := f.service.Refresh(context.Background(), "not a token"); err != errs.ErrInvalidToken {
+		t.Fatalf("want ErrInvalidToken, got %v", err)
+	}
+}
+
+func TestAuthServiceVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    models.User
+		email   string
+		wantErr error
+	}{
+		{name: "unverified address", user: models.User{Username: "alice", Email: "alice@example.com"},
+			email: "alice@example.com"},
+		{name: "already verified", user: models.User{Username: "alice", Email: "alice@example.com",
+			EmailVerified: true}, email: "alice@example.com", wantErr: errs.ErrEmailAlreadyVerified},
+		{name: "address changed since the link was sent", user: models.User{Username: "alice",
+			Email: "alice@example.com"}, email: "old@example.com", wantErr: errs.ErrEmailAlreadyVerified},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuthFixture(t)
+			userID := f.addUser(t, tt.user, testPassword)
+			token, err := pkg.GenerateToken(userID, tt.user.Username, tt.email, models.RoleUser,
+				pkg.TokenTypeEmailVerification, 0)
+			if err != nil {
+				t.Fatalf("generating token: %v", err)
+			}
+
+			if err := f.service.VerifyEmail(ctx, token); err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if user, _ := f.users.GetUserByID(ctx, userID); !user.EmailVerified && tt.wantErr == nil {
+				t.Fatalf("address was not verified")
+			}
+		})
+	}
+}
+
+var resetTokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+func TestAuthServiceResetPassword(t *testing.T) {
+	const newPassword = "a brand new password"
+
+	tests := []struct {
+		name     string
+		token    func(t *testing.T, f *authFixture) string
+		password string
+		wantErr  error
+	}{
+		{
+			name:     "valid token",
+			token:    requestResetToken,
+			password: newPassword,
+		},
+		{
+			name:     "short password",
+			token:    requestResetToken,
+			password: "short",
+			wantErr:  errs.ErrInvalidPassword,
+		},
+		{
+			name:     "unknown token",
+			token:    func(t *testing.T, f *authFixture) string { return "bogus" },
+			password: newPassword,
+			wantErr:  errs.ErrResetTokenInvalid,
+		},
+		{
+			name: "used token",
+			token: func(t *testing.T, f *authFixture) string {
+				token := requestResetToken(t, f)
+				if err := f.service.ResetPassword(context.Background(), token, newPassword); err != nil {
+					t.Fatalf("first reset: %v", err)
+				}
+				return token
+			},
+			password: newPassword,
+			wantErr:  errs.ErrResetTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuthFixture(t)
+			userID := f.addUser(t, models.User{Username: "alice", Email: "alice@example.com"}, testPassword)
+			token := tt.token(t, f)
+
+			err := f.service.ResetPassword(ctx, token, tt.password)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			user, _ := f.users.GetUserByID(ctx, userID)
+			if !utils.CheckPasswordHash(tt.password, user.Password) {
+				t.Fatalf("password was not updated")
+			}
+			if !user.EmailVerified {
+				t.Fatalf("a reset via the emailed link must verify the address")
+			}
+			if _, ok := f.revocations.revokedBefore[userID]; !ok {
+				t.Fatalf("access tokens were not revoked")
+			}
+			if len(f.apiKeys.revokedFor) != 1 || f.apiKeys.revokedFor[0] != userID {
+				t.Fatalf("API keys were not revoked: %v", f.apiKeys.revokedFor)
+			}
+		})
+	}
+}
+
+// requestResetToken runs ForgotPassword for alice and extracts the token
+// from the mailed link.
+func requestResetToken(t *testing.T, f *authFixture) string {
+	t.Helper()
+	if err := f.service.ForgotPassword(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("forgot password: %v", err)
+	}
+	match := resetTokenPattern.FindStringSubmatch(f.mailer.last().Body)
+	if match == nil {
+		t.Fatalf("no reset link in %q", f.mailer.last().Body)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatalf("unescaping token: %v", err)
+	}
+	return token
+}
@@ -5,57 +5,264 @@ import (
 	"auction/internal/models"
 	"auction/internal/repository"
 	"context"
-	"fmt"
+	"time"
 )
 
+// AntiSniping extends a lot's end time by Extension when a bid arrives less
+// than Window before it ends. A zero Window disables extensions.
+type AntiSniping struct {
+	Window    time.Duration
+	Extension time.Duration
+}
+
 type BidService struct {
-	bidRepo repository.BidRepository
-	lotRepo repository.LotRepository
+	bidRepo    repository.BidRepository
+	lotRepo    repository.LotRepository
+	proxyRepo  repository.ProxyBidRepository
+	userRepo   repository.UserRepository
+	tx         repository.Transactor
+	increments models.IncrementSchedule
+	antiSnipe  AntiSniping
 }
 
-func NewBidService(bidRepo repository.BidRepository, lotRepo repository.LotRepository) *BidService {
+func NewBidService(bidRepo repository.BidRepository, lotRepo repository.LotRepository,
+	proxyRepo repository.ProxyBidRepository, userRepo repository.UserRepository, tx repository.Transactor,
+	increments models.IncrementSchedule, antiSnipe AntiSniping) *BidService {
 	return &BidService{
-		bidRepo: bidRepo,
-		lotRepo: lotRepo,
+		bidRepo:    bidRepo,
+		lotRepo:    lotRepo,
+		proxyRepo:  proxyRepo,
+		userRepo:   userRepo,
+		tx:         tx,
+		increments: increments,
+		antiSnipe:  antiSnipe,
 	}
 }
 
 func (s *BidService) CreateBid(ctx context.Context, userID int, bid models.PlaceBid) (*models.BidResponse, error) {
+	if bid.MaxAmount > 0 && bid.Amount > bid.MaxAmount {
+		return nil, errs.ErrInvalidMaxAmount
+	}
+	maxAmount := max(bid.Amount, bid.MaxAmount)
+
+	if err := checkEmailVerified(ctx, s.userRepo, userID); err != nil {
+		return nil, err
+	}
+
 	lot, err := s.lotRepo.GetLotByID(ctx, bid.LotID)
 	if err != nil {
 		return nil, err
 	}
-	if lot == nil {
-		return nil, fmt.Errorf("lot %d not found", errs.ErrFoundLot)
+	if lot.AuctionType == models.AuctionTypeDutch {
+		return nil, errs.ErrDutchAuctionBid
 	}
-	if bid.Amount <= lot.CurrentPrice {
-		return nil, errs.ErrBidTooLow
-	}
-	if lot.UserID == userID {
-		return nil, errs.ErrCannotBidOnOwnLot
+	if isSealed(lot) && bid.MaxAmount > 0 {
+		return nil, errs.ErrProxyBidNotAllowed
 	}
-	bidData := models.BidCreate{
-		LotID:  bid.LotID,
-		UserID: userID,
-		Amount: bid.Amount,
+	if err := s.validateBid(lot, userID, maxAmount); err != nil {
+		return nil, err
 	}
 
-	bidID, err := s.bidRepo.CreateBid(ctx, bidData)
+	var response *models.BidResponse
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		lot, err := s.lotRepo.GetLotForUpdate(ctx, bid.LotID)
+		if err != nil {
+			return err
+		}
+		if err := s.validateBid(lot, userID, maxAmount); err != nil {
+			if err == errs.ErrBidTooLow {
+				return errs.ErrBidConflict
+			}
+			return err
+		}
+		if isSealed(lot) {
+			response, err = s.placeSealedBid(ctx, lot, userID, bid.Amount)
+			return err
+		}
+		response, err = s.resolveBid(ctx, lot, userID, bid, maxAmount)
+		if err != nil {
+			return err
+		}
+		response.EndTime, err = s.extendIfSniped(ctx, lot, response)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	err = s.lotRepo.UpdateLotPrice(ctx, bid.LotID, bid.Amount)
+	return response, nil
+}
+
+// placeSealedBid keeps one bid per user on a sealed lot, revising it in place.
+// The lot price is left untouched so nothing is revealed until closing.
+func (s *BidService) placeSealedBid(ctx context.Context, lot *models.LotResponse, userID int,
+	amount int) (*models.BidResponse, error) {
+	existing, err := s.bidRepo.GetUserBidForLot(ctx, lot.ID, userID)
+	if err != nil && err != errs.ErrNoBids {
+		return nil, err
+	}
+	bidID := 0
+	if existing != nil {
+		bidID = existing.ID
+		err = s.bidRepo.UpdateBidAmount(ctx, bidID, amount)
+	} else {
+		bidID, err = s.bidRepo.CreateBid(ctx, models.BidCreate{
+			LotID:  lot.ID,
+			UserID: userID,
+			Amount: amount,
+		})
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &models.BidResponse{
-		ID:     bidID,
-		LotID:  bid.LotID,
-		UserID: userID,
-		Amount: bid.Amount,
+		ID:      bidID,
+		LotID:   lot.ID,
+		UserID:  userID,
+		Amount:  amount,
+		EndTime: lot.EndTime,
 	}, nil
 }
 
+// resolveBid places the bid against the current leader. Only the price needed
+// to lead is revealed: the winner pays the loser's maximum plus an increment.
+// Must be called with the lot row locked.
+func (s *BidService) resolveBid(ctx context.Context, lot *models.LotResponse, userID int,
+	bid models.PlaceBid, maxAmount int) (*models.BidResponse, error) {
+	if bid.MaxAmount > 0 {
+		err := s.proxyRepo.UpsertProxyBid(ctx, models.ProxyBid{
+			LotID:     lot.ID,
+			UserID:    userID,
+			MaxAmount: bid.MaxAmount,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	leader, leaderMax, err := s.currentLeader(ctx, lot)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BidResponse{
+		LotID:        lot.ID,
+		UserID:       userID,
+		MaxAmount:    bid.MaxAmount,
+		CurrentPrice: lot.CurrentPrice,
+	}
+
+	schedule := incrementSchedule(lot, s.increments)
+	switch {
+	case leader == userID:
+		response.Leading = true
+		if bid.Amount <= lot.CurrentPrice {
+			return response, nil
+		}
+		// Raising one's own price is still a bid and must respect the schedule.
+		if bid.Amount < nextMinimumBid(lot, s.increments) {
+			return nil, errs.ErrBidTooLow
+		}
+		response.Amount = bid.Amount
+	case leader == 0 || maxAmount > leaderMax:
+		if leader != 0 && leaderMax > lot.CurrentPrice {
+			if _, err := s.bidRepo.CreateBid(ctx, models.BidCreate{
+				LotID: lot.ID, UserID: leader, Amount: leaderMax,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		response.Leading = true
+		response.Amount = max(min(maxAmount, leaderMax+schedule.Increment(leaderMax)), bid.Amount)
+		if lot.ReservePrice > 0 && maxAmount >= lot.ReservePrice {
+			response.Amount = max(response.Amount, lot.ReservePrice)
+		}
+	default:
+		// The leader's maximum covers this bid. On a tie the earlier maximum
+		// wins, so the leader's counter-bid is recorded first.
+		price := min(leaderMax, maxAmount+schedule.Increment(maxAmount))
+		if price == maxAmount {
+			if _, err := s.bidRepo.CreateBid(ctx, models.BidCreate{
+				LotID: lot.ID, UserID: leader, Amount: price,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		response.ID, err = s.bidRepo.CreateBid(ctx, models.BidCreate{
+			LotID: lot.ID, UserID: userID, Amount: maxAmount,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if price > maxAmount {
+			if _, err := s.bidRepo.CreateBid(ctx, models.BidCreate{
+				LotID: lot.ID, UserID: leader, Amount: price,
+			}); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.lotRepo.RaiseLotPrice(ctx, lot.ID, price); err != nil {
+			return nil, err
+		}
+		response.Amount = maxAmount
+		response.CurrentPrice = price
+		return response, nil
+	}
+
+	response.ID, err = s.bidRepo.CreateBid(ctx, models.BidCreate{
+		LotID: lot.ID, UserID: userID, Amount: response.Amount,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lotRepo.RaiseLotPrice(ctx, lot.ID, response.Amount); err != nil {
+		return nil, err
+	}
+	response.CurrentPrice = response.Amount
+	return response, nil
+}
+
+func (s *BidService) extendIfSniped(ctx context.Context, lot *models.LotResponse,
+	response *models.BidResponse) (time.Time, error) {
+	if response.ID == 0 || s.antiSnipe.Window <= 0 || time.Until(lot.EndTime) > s.antiSnipe.Window {
+		return lot.EndTime, nil
+	}
+	return s.lotRepo.ExtendLotEndTime(ctx, lot.ID, s.antiSnipe.Extension)
+}
+
+// currentLeader returns the highest bidder and the most they are willing to
+// pay, which is their proxy maximum or, without one, the current price.
+func (s *BidService) currentLeader(ctx context.Context, lot *models.LotResponse) (int, int, error) {
+	highest, err := s.bidRepo.GetHighestBid(ctx, lot.ID)
+	if err == errs.ErrNoBids {
+		return 0, lot.CurrentPrice, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	leaderMax := lot.CurrentPrice
+	proxy, err := s.proxyRepo.GetProxyBid(ctx, lot.ID, highest.UserID)
+	if err != nil && err != errs.ErrProxyBidNotFound {
+		return 0, 0, err
+	}
+	if proxy != nil && proxy.MaxAmount > leaderMax {
+		leaderMax = proxy.MaxAmount
+	}
+	return highest.UserID, leaderMax, nil
+}
+
+func (s *BidService) validateBid(lot *models.LotResponse, userID int, amount int) error {
+	if err := checkLotOpen(lot, time.Now()); err != nil {
+		return err
+	}
+	if lot.UserID == userID {
+		return errs.ErrCannotBidOnOwnLot
+	}
+	if amount < nextMinimumBid(lot, s.increments) {
+		return errs.ErrBidTooLow
+	}
+	return nil
+}
+
 func (s *BidService) GetMyBids(ctx context.Context, userID int) ([]models.Bid, error) {
 	bidInfos, err := s.bidRepo.GetMyBids(ctx, userID)
 	if err != nil {
@@ -77,3 +284,25 @@ func (s *BidService) GetMyBids(ctx context.Context, userID int) ([]models.Bid, e
 	}
 	return bids, nil
 }
+
+func checkLotOpen(lot *models.LotResponse, now time.Time) error {
+	switch lot.Status {
+	case models.LotStatusCancelled:
+		return errs.ErrLotCancelled
+	case models.LotStatusActive:
+	default:
+		return errs.ErrLotClosed
+	}
+	if now.Before(lot.StartTime) {
+		return errs.ErrLotNotStarted
+	}
+	if !now.Before(lot.EndTime) {
+		return errs.ErrLotClosed
+	}
+	return nil
+}
+
+func isSealed(lot *models.LotResponse) bool {
+	return lot.AuctionType == models.AuctionTypeSealedFirstPrice ||
+		lot.AuctionType == models.AuctionTypeSealedSecondPrice
+}
@@ -0,0 +1,306 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+const (
+	bob   = 2
+	carol = 3
+)
+
+type bidStep struct {
+	userID    int
+	amount    int
+	maxAmount int
+	wantErr   error
+}
+
+func TestBidServiceResolveBid(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       []bidStep
+		wantPrice   int
+		wantLeader  int
+		wantLeading bool
+		check       func(t *testing.T, f *auctionFixture, lotID int)
+	}{
+		{
+			name:  "first bid at the start price",
+			steps: []bidStep{{userID: bob, amount: 100, wantErr: errs.ErrBidTooLow}},
+			check: func(t *testing.T, f *auctionFixture, lotID int) {
+				if len(f.bids.bids) != 0 {
+					t.Fatalf("want no bids, got %d", len(f.bids.bids))
+				}
+			},
+			wantPrice: 100,
+		},
+		{
+			name:        "first bid one increment over the start price",
+			steps:       []bidStep{{userID: bob, amount: 110}},
+			wantPrice:   110,
+			wantLeader:  bob,
+			wantLeading: true,
+		},
+		{
+			name:        "first proxy bids one increment over the start price",
+			steps:       []bidStep{{userID: bob, amount: 110, maxAmount: 300}},
+			wantPrice:   110,
+			wantLeader:  bob,
+			wantLeading: true,
+		},
+		{
+			name: "proxy defends against a lower maximum",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: carol, amount: 120, maxAmount: 250},
+			},
+			wantPrice:  260,
+			wantLeader: bob,
+		},
+		{
+			name: "higher maximum outbids the leading proxy",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: carol, amount: 120, maxAmount: 250},
+				{userID: carol, amount: 270, maxAmount: 400},
+			},
+			wantPrice:   310,
+			wantLeader:  carol,
+			wantLeading: true,
+			check: func(t *testing.T, f *auctionFixture, lotID int) {
+				// The outbid proxy's maximum is on record as its last bid.
+				bids, _ := f.bids.GetTopBids(context.Background(), lotID, 2)
+				if bids[1].UserID != bob || bids[1].Amount != 300 {
+					t.Fatalf("want bob's maximum of 300 as the runner-up bid, got %+v", bids[1])
+				}
+			},
+		},
+		{
+			name: "tie goes to the earlier maximum",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: carol, amount: 300},
+			},
+			wantPrice:  300,
+			wantLeader: bob,
+		},
+		{
+			name: "tie between two proxies goes to the earlier one",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: carol, amount: 120, maxAmount: 300},
+			},
+			wantPrice:  300,
+			wantLeader: bob,
+		},
+		{
+			name: "leader raises their own maximum without raising the price",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: bob, amount: 110, maxAmount: 500},
+			},
+			wantPrice:   110,
+			wantLeader:  bob,
+			wantLeading: true,
+			check: func(t *testing.T, f *auctionFixture, lotID int) {
+				proxy, _ := f.proxies.GetProxyBid(context.Background(), lotID, bob)
+				if proxy.MaxAmount != 500 {
+					t.Fatalf("want maximum 500, got %d", proxy.MaxAmount)
+				}
+				if len(f.bids.bids) != 1 {
+					t.Fatalf("raising a maximum must not place a bid, got %d bids", len(f.bids.bids))
+				}
+			},
+		},
+		{
+			name: "raised maximum defends against a later bidder",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: bob, amount: 110, maxAmount: 500},
+				{userID: carol, amount: 400},
+			},
+			wantPrice:  410,
+			wantLeader: bob,
+		},
+		{
+			name: "leader raising their own price respects the increment",
+			steps: []bidStep{
+				{userID: bob, amount: 110, maxAmount: 300},
+				{userID: bob, amount: 115, maxAmount: 500, wantErr: errs.ErrBidTooLow},
+			},
+			wantPrice:  110,
+			wantLeader: bob,
+		},
+		{
+			name: "leader raises their own price by an increment",
+			steps: []bidStep{
+				{userID: bob, amount: 110},
+				{userID: bob, amount: 120},
+			},
+			wantPrice:   120,
+			wantLeader:  bob,
+			wantLeading: true,
+		},
+		{
+			name: "plain bid below the next minimum",
+			steps: []bidStep{
+				{userID: bob, amount: 110},
+				{userID: carol, amount: 119, wantErr: errs.ErrBidTooLow},
+			},
+			wantPrice:  110,
+			wantLeader: bob,
+		},
+		{
+			name:  "seller cannot bid",
+			steps: []bidStep{{userID: sellerID, amount: 110, wantErr: errs.ErrCannotBidOnOwnLot}},
+			check: func(t *testing.T, f *auctionFixture, lotID int) {
+				if len(f.bids.bids) != 0 {
+					t.Fatalf("want no bids, got %d", len(f.bids.bids))
+				}
+			},
+			wantPrice: 100,
+		},
+		{
+			name:  "maximum below the amount",
+			steps: []bidStep{{userID: bob, amount: 200, maxAmount: 150, wantErr: errs.ErrInvalidMaxAmount}},
+			check: func(t *testing.T, f *auctionFixture, lotID int) {
+				if len(f.bids.bids) != 0 {
+					t.Fatalf("want no bids, got %d", len(f.bids.bids))
+				}
+			},
+			wantPrice: 100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuctionFixture(t, AntiSniping{})
+			lotID := f.addLot(t, models.LotCreate{StartPrice: 100})
+
+			var response *models.BidResponse
+			for i, step := range tt.steps {
+				var err error
+				response, err = f.bidService.CreateBid(ctx, step.userID, models.PlaceBid{
+					LotID: lotID, Amount: step.amount, MaxAmount: step.maxAmount,
+				})
+				if err != step.wantErr {
+					t.Fatalf("step %d: want error %v, got %v", i+1, step.wantErr, err)
+				}
+			}
+
+			if price := f.lot(t, lotID).CurrentPrice; price != tt.wantPrice {
+				t.Fatalf("want price %d, got %d", tt.wantPrice, price)
+			}
+			if tt.wantLeader != 0 {
+				highest, err := f.bids.GetHighestBid(ctx, lotID)
+				if err != nil {
+					t.Fatalf("highest bid: %v", err)
+				}
+				if highest.UserID != tt.wantLeader {
+					t.Fatalf("want user %d leading, got %d", tt.wantLeader, highest.UserID)
+				}
+			}
+			if last := tt.steps[len(tt.steps)-1]; last.wantErr == nil && response.Leading != tt.wantLeading {
+				t.Fatalf("want leading %v, got %v", tt.wantLeading, response.Leading)
+			}
+			if tt.check != nil {
+				tt.check(t, f, lotID)
+			}
+		})
+	}
+}
+
+func TestBidServiceAntiSniping(t *testing.T) {
+	antiSnipe := AntiSniping{Window: 5 * time.Minute, Extension: 2 * time.Minute}
+	tests := []struct {
+		name          string
+		endsIn        time.Duration
+		window        time.Duration
+		leaderRaise   bool
+		wantExtension time.Duration
+	}{
+		{name: "bid inside the window", endsIn: time.Minute, window: antiSnipe.Window,
+			wantExtension: antiSnipe.Extension},
+		{name: "bid at the window edge", endsIn: 4*time.Minute + 59*time.Second, window: antiSnipe.Window,
+			wantExtension: antiSnipe.Extension},
+		{name: "bid outside the window", endsIn: time.Hour, window: antiSnipe.Window},
+		{name: "extensions disabled", endsIn: time.Minute},
+		{name: "raising a maximum places no bid", endsIn: time.Minute, window: antiSnipe.Window,
+			leaderRaise: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuctionFixture(t, AntiSniping{Window: tt.window, Extension: antiSnipe.Extension})
+			endTime := time.Now().Add(tt.endsIn)
+			lotID := f.addLot(t, models.LotCreate{StartPrice: 100, EndTime: endTime})
+			if tt.leaderRaise {
+				if _, err := f.bids.CreateBid(ctx, models.BidCreate{LotID: lotID, UserID: bob, Amount: 110}); err != nil {
+					t.Fatal(err)
+				}
+				f.lots.UpdateLotPrice(ctx, lotID, 110)
+			}
+
+			response, err := f.bidService.CreateBid(ctx, bob, models.PlaceBid{LotID: lotID, Amount: 110,
+				MaxAmount: 300})
+			if err != nil {
+				t.Fatalf("bid: %v", err)
+			}
+
+			lot := f.lot(t, lotID)
+			wantEnd := endTime.Add(tt.wantExtension)
+			if !lot.EndTime.Equal(wantEnd) || !response.EndTime.Equal(wantEnd) {
+				t.Fatalf("want end %v, got lot %v and response %v", wantEnd, lot.EndTime, response.EndTime)
+			}
+			wantCount := 0
+			if tt.wantExtension > 0 {
+				wantCount = 1
+			}
+			if lot.ExtensionCount != wantCount {
+				t.Fatalf("want %d extensions, got %d", wantCount, lot.ExtensionCount)
+			}
+		})
+	}
+}
+
+func TestBidServiceSealedBids(t *testing.T) {
+	ctx := context.Background()
+	f := newAuctionFixture(t, AntiSniping{})
+	lotID := f.addLot(t, models.LotCreate{StartPrice: 100, AuctionType: models.AuctionTypeSealedSecondPrice})
+
+	if _, err := f.bidService.CreateBid(ctx, bob, models.PlaceBid{LotID: lotID, Amount: 99}); err != errs.ErrBidTooLow {
+		t.Fatalf("bid below start: want ErrBidTooLow, got %v", err)
+	}
+	if _, err := f.bidService.CreateBid(ctx, bob, models.PlaceBid{LotID: lotID, Amount: 150,
+		MaxAmount: 200}); err != errs.ErrProxyBidNotAllowed {
+		t.Fatalf("proxy on sealed lot: want ErrProxyBidNotAllowed, got %v", err)
+	}
+	for _, amount := range []int{100, 180} {
+		if _, err := f.bidService.CreateBid(ctx, bob, models.PlaceBid{LotID: lotID, Amount: amount}); err != nil {
+			t.Fatalf("sealed bid %d: %v", amount, err)
+		}
+	}
+	if len(f.bids.bids) != 1 || f.bids.bids[0].Amount != 180 {
+		t.Fatalf("want one revised bid of 180, got %+v", f.bids.bids)
+	}
+	if price := f.lot(t, lotID).CurrentPrice; price != 100 {
+		t.Fatalf("sealed bids must not move the price, got %d", price)
+	}
+}
+
+func TestBidServiceDutchLotRejectsBids(t *testing.T) {
+	f := newAuctionFixture(t, AntiSniping{})
+	lotID := f.addLot(t, models.LotCreate{StartPrice: 1000, AuctionType: models.AuctionTypeDutch, FloorPrice: 500,
+		PriceDecrement: 50, DecrementSeconds: 60})
+	_, err := f.bidService.CreateBid(context.Background(), bob, models.PlaceBid{LotID: lotID, Amount: 1000})
+	if err != errs.ErrDutchAuctionBid {
+		t.Fatalf("want ErrDutchAuctionBid, got %v", err)
+	}
+}
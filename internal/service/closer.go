@@ -0,0 +1,121 @@
+package service
+
+import (
+	"auction/internal/models"
+	"auction/internal/repository"
+	"context"
+	"log"
+	"time"
+)
+
+type AuctionCloser struct {
+	lotRepo    repository.LotRepository
+	bidRepo    repository.BidRepository
+	winnerRepo repository.WinnerRepository
+	tx         repository.Transactor
+	interval   time.Duration
+}
+
+func NewAuctionCloser(lotRepo repository.LotRepository, bidRepo repository.BidRepository,
+	winnerRepo repository.WinnerRepository, tx repository.Transactor, interval time.Duration) *AuctionCloser {
+	return &AuctionCloser{
+		lotRepo:    lotRepo,
+		bidRepo:    bidRepo,
+		winnerRepo: winnerRepo,
+		tx:         tx,
+		interval:   interval,
+	}
+}
+
+// Run closes expired lots every interval until ctx is cancelled.
+func (c *AuctionCloser) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.CloseExpiredLots(ctx); err != nil {
+			log.Printf("error closing expired lots: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *AuctionCloser) CloseExpiredLots(ctx context.Context) error {
+	now := time.Now()
+	lotIDs, err := c.lotRepo.GetExpiredLotIDs(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, lotID := range lotIDs {
+		if err := c.closeLot(ctx, lotID, now); err != nil {
+			log.Printf("error closing lot %d: %v", lotID, err)
+		}
+	}
+	return nil
+}
+
+func (c *AuctionCloser) closeLot(ctx context.Context, lotID int, now time.Time) error {
+	return c.tx.WithinTx(ctx, func(ctx context.Context) error {
+		lot, err := c.lotRepo.GetLotForUpdate(ctx, lotID)
+		if err != nil {
+			return err
+		}
+		if lot.Status != models.LotStatusActive || lot.EndTime.After(now) {
+			return nil
+		}
+		bids, err := c.bidRepo.GetTopBids(ctx, lotID, 2)
+		if err != nil {
+			return err
+		}
+		// Without bids a lot with a reserve did not meet it either.
+		if lot.ReservePrice > 0 && (len(bids) == 0 || bids[0].Amount < lot.ReservePrice) {
+			reason := models.LotReasonReserveNotMet
+			if len(bids) == 0 {
+				reason = models.LotReasonNoBids
+			}
+			return c.lotRepo.UpdateLotStatus(ctx, models.LotStatusChange{
+				LotID:      lotID,
+				FromStatus: lot.Status,
+				ToStatus:   models.LotStatusUnsold,
+				Reason:     reason,
+			})
+		}
+		if len(bids) > 0 {
+			price := settlementPrice(lot, bids)
+			if err := c.lotRepo.UpdateLotPrice(ctx, lotID, price); err != nil {
+				return err
+			}
+			err = c.winnerRepo.CreateWinner(ctx, models.Winner{
+				LotID:   lotID,
+				UserID:  bids[0].UserID,
+				Amount:  price,
+				WinDate: now,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return c.lotRepo.UpdateLotStatus(ctx, models.LotStatusChange{
+			LotID:      lotID,
+			FromStatus: lot.Status,
+			ToStatus:   models.LotStatusClosed,
+			Reason:     models.LotReasonExpired,
+		})
+	})
+}
+
+// settlementPrice returns what the highest bidder pays. In a second-price
+// (Vickrey) auction that is the runner-up's bid, never below start or reserve.
+func settlementPrice(lot *models.LotResponse, bids []models.Bid) int {
+	if lot.AuctionType != models.AuctionTypeSealedSecondPrice {
+		return bids[0].Amount
+	}
+	price := lot.StartPrice
+	if len(bids) > 1 {
+		price = bids[1].Amount
+	}
+	return max(price, lot.ReservePrice)
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"auction/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSettlementPrice(t *testing.T) {
+	bids := func(amounts ...int) []models.Bid {
+		var bids []models.Bid
+		for i, amount := range amounts {
+			bids = append(bids, models.Bid{UserID: i + 2, Amount: amount})
+		}
+		return bids
+	}
+	tests := []struct {
+		name string
+		lot  models.LotResponse
+		bids []models.Bid
+		want int
+	}{
+		{name: "english pays the highest bid", lot: models.LotResponse{AuctionType: models.AuctionTypeEnglish,
+			StartPrice: 100}, bids: bids(300, 250), want: 300},
+		{name: "first price pays the highest bid", lot: models.LotResponse{
+			AuctionType: models.AuctionTypeSealedFirstPrice, StartPrice: 100}, bids: bids(300, 250), want: 300},
+		{name: "vickrey pays the runner-up", lot: models.LotResponse{
+			AuctionType: models.AuctionTypeSealedSecondPrice, StartPrice: 100}, bids: bids(300, 250), want: 250},
+		{name: "vickrey with one bid pays the start price", lot: models.LotResponse{
+			AuctionType: models.AuctionTypeSealedSecondPrice, StartPrice: 100}, bids: bids(300), want: 100},
+		{name: "vickrey with one bid pays the reserve above start", lot: models.LotResponse{
+			AuctionType: models.AuctionTypeSealedSecondPrice, StartPrice: 100, ReservePrice: 200},
+			bids: bids(300), want: 200},
+		{name: "vickrey runner-up below the reserve pays the reserve", lot: models.LotResponse{
+			AuctionType: models.AuctionTypeSealedSecondPrice, StartPrice: 100, ReservePrice: 200},
+			bids: bids(300, 150), want: 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := settlementPrice(&tt.lot, tt.bids); got != tt.want {
+				t.Fatalf("want %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuctionCloserCloseExpiredLots(t *testing.T) {
+	tests := []struct {
+		name       string
+		lot        models.LotCreate
+		bids       []models.BidCreate
+		wantStatus string
+		wantReason string
+		wantWinner *models.Winner
+	}{
+		{
+			name:       "highest bidder wins",
+			lot:        models.LotCreate{StartPrice: 100, CurrentPrice: 160},
+			bids:       []models.BidCreate{{UserID: bob, Amount: 150}, {UserID: carol, Amount: 160}},
+			wantStatus: models.LotStatusClosed,
+			wantReason: models.LotReasonExpired,
+			wantWinner: &models.Winner{UserID: carol, Amount: 160},
+		},
+		{
+			name:       "vickrey winner pays the runner-up",
+			lot:        models.LotCreate{StartPrice: 100, AuctionType: models.AuctionTypeSealedSecondPrice},
+			bids:       []models.BidCreate{{UserID: bob, Amount: 150}, {UserID: carol, Amount: 400}},
+			wantStatus: models.LotStatusClosed,
+			wantReason: models.LotReasonExpired,
+			wantWinner: &models.Winner{UserID: carol, Amount: 150},
+		},
+		{
+			name:       "reserve not met",
+			lot:        models.LotCreate{StartPrice: 100, CurrentPrice: 150, ReservePrice: 200},
+			bids:       []models.BidCreate{{UserID: bob, Amount: 150}},
+			wantStatus: models.LotStatusUnsold,
+			wantReason: models.LotReasonReserveNotMet,
+		},
+		{
+			name:       "reserve met",
+			lot:        models.LotCreate{StartPrice: 100, CurrentPrice: 200, ReservePrice: 200},
+			bids:       []models.BidCreate{{UserID: bob, Amount: 200}},
+			wantStatus: models.LotStatusClosed,
+			wantReason: models.LotReasonExpired,
+			wantWinner: &models.Winner{UserID: bob, Amount: 200},
+		},
+		{
+			name:       "reserve with no bids",
+			lot:        models.LotCreate{StartPrice: 100, ReservePrice: 200},
+			wantStatus: models.LotStatusUnsold,
+			wantReason: models.LotReasonNoBids,
+		},
+		{
+			name:       "no bids",
+			lot:        models.LotCreate{StartPrice: 100},
+			wantStatus: models.LotStatusClosed,
+			wantReason: models.LotReasonExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuctionFixture(t, AntiSniping{})
+			tt.lot.StartTime = time.Now().Add(-2 * time.Hour)
+			tt.lot.EndTime = time.Now().Add(-time.Minute)
+			lotID := f.addLot(t, tt.lot)
+			for _, bid := range tt.bids {
+				bid.LotID = lotID
+				if _, err := f.bids.CreateBid(ctx, bid); err != nil {
+					t.Fatal(err)
+				}
+			}
+			openID := f.addLot(t, models.LotCreate{StartPrice: 100})
+
+			if err := f.closer.CloseExpiredLots(ctx); err != nil {
+				t.Fatalf("closing lots: %v", err)
+			}
+
+			if status := f.lot(t, openID).Status; status != models.LotStatusActive {
+				t.Fatalf("a running lot was closed: %s", status)
+			}
+			lot := f.lot(t, lotID)
+			if lot.Status != tt.wantStatus {
+				t.Fatalf("want status %s, got %s", tt.wantStatus, lot.Status)
+			}
+			history, _ := f.lots.GetStatusHistory(ctx, lotID)
+			if len(history) != 1 || history[0].Reason != tt.wantReason {
+				t.Fatalf("want one change with reason %q, got %+v", tt.wantReason, history)
+			}
+			winner, err := f.winners.GetWinnerByLotID(ctx, lotID)
+			if tt.wantWinner == nil {
+				if err == nil {
+					t.Fatalf("want no winner, got %+v", winner)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("winner: %v", err)
+			}
+			if winner.UserID != tt.wantWinner.UserID || winner.Amount != tt.wantWinner.Amount {
+				t.Fatalf("want winner %+v, got %+v", tt.wantWinner, winner)
+			}
+			if lot.CurrentPrice != tt.wantWinner.Amount {
+				t.Fatalf("want final price %d, got %d", tt.wantWinner.Amount, lot.CurrentPrice)
+			}
+		})
+	}
+}
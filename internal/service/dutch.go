@@ -0,0 +1,22 @@
+package service
+
+import (
+	"auction/internal/models"
+	"time"
+)
+
+// dutchPrice drops the start price by PriceDecrement every DecrementSeconds
+// since the lot started, never going below FloorPrice.
+func dutchPrice(lot *models.LotResponse, now time.Time) int {
+	if lot.DecrementSeconds <= 0 || now.Before(lot.StartTime) {
+		return lot.StartPrice
+	}
+	steps := int(now.Sub(lot.StartTime) / (time.Duration(lot.DecrementSeconds) * time.Second))
+	return max(lot.StartPrice-steps*lot.PriceDecrement, lot.FloorPrice)
+}
+
+func validateDutchLot(lot models.Lot) bool {
+	return lot.FloorPrice > 0 && lot.FloorPrice < lot.StartPrice &&
+		lot.PriceDecrement > 0 && lot.DecrementSeconds > 0 &&
+		lot.ReservePrice == 0 && lot.BuyNowPrice == 0
+}
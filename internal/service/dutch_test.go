@@ -0,0 +1,38 @@
+package service
+
+import (
+	"auction/internal/models"
+	"testing"
+	"time"
+)
+
+func TestDutchPrice(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	lot := models.LotResponse{
+		StartPrice:       1000,
+		FloorPrice:       600,
+		PriceDecrement:   50,
+		DecrementSeconds: 60,
+		StartTime:        start,
+	}
+	tests := []struct {
+		name string
+		now  time.Time
+		want int
+	}{
+		{name: "before start", now: start.Add(-time.Minute), want: 1000},
+		{name: "at start", now: start, want: 1000},
+		{name: "within the first step", now: start.Add(59 * time.Second), want: 1000},
+		{name: "after one step", now: start.Add(time.Minute), want: 950},
+		{name: "after five steps", now: start.Add(5*time.Minute + 30*time.Second), want: 750},
+		{name: "reaches the floor", now: start.Add(8 * time.Minute), want: 600},
+		{name: "never below the floor", now: start.Add(24 * time.Hour), want: 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dutchPrice(&lot, tt.now); got != tt.want {
+				t.Fatalf("want %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,1052 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/mail"
+	"auction/internal/models"
+	"auction/internal/oidc"
+	"auction/internal/pkg"
+	"auction/internal/utils"
+	"context"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var setupKeysOnce sync.Once
+
+// setupKeys installs an in-memory signing key for token generation.
+func setupKeys(t *testing.T) {
+	t.Helper()
+	setupKeysOnce.Do(func() {
+		keyManager, err := pkg.NewKeyManager("EdDSA", "", pkg.RefreshTokenTTL)
+		if err != nil {
+			t.Fatalf("creating key manager: %v", err)
+		}
+		pkg.SetKeyManager(keyManager)
+	})
+}
+
+type fakeUserRepo struct {
+	mu     sync.Mutex
+	nextID int
+	users  map[int]*models.User
+}
+
+func newFakeUserRepo() *fakeUserRepo {
+	return &fakeUserRepo{users: map[int]*models.User{}}
+}
+
+func (r *fakeUserRepo) CreateUser(ctx context.Context, user models.User) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	user.ID = r.nextID
+	r.users[user.ID] = &user
+	return user.ID, nil
+}
+
+func (r *fakeUserRepo) find(match func(u *models.User) bool) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, user := range r.users {
+		if match(user) {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, errs.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) GetUserByID(ctx context.Context, id int) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.ID == id })
+}
+
+func (r *fakeUserRepo) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Username == username })
+}
+
+func (r *fakeUserRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return strings.EqualFold(u.Email, email) })
+}
+
+func (r *fakeUserRepo) update(userID int, fn func(u *models.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok {
+		return errs.ErrUserNotFound
+	}
+	fn(user)
+	return nil
+}
+
+func (r *fakeUserRepo) UpdatePassword(ctx context.Context, userID int, passwordHash string) error {
+	return r.update(userID, func(u *models.User) { u.Password = passwordHash })
+}
+
+func (r *fakeUserRepo) MarkEmailVerified(ctx context.Context, userID int, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user, ok := r.users[userID]
+	if !ok || user.Email != email || user.EmailVerified {
+		return errs.ErrEmailAlreadyVerified
+	}
+	user.EmailVerified = true
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateUserRole(ctx context.Context, userID int, role string) error {
+	return r.update(userID, func(u *models.User) { u.Role = role })
+}
+
+func (r *fakeUserRepo) IsEmailVerified(ctx context.Context, userID int) (bool, error) {
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+type fakeRefreshRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*models.RefreshToken
+}
+
+func newFakeRefreshRepo() *fakeRefreshRepo {
+	return &fakeRefreshRepo{tokens: map[string]*models.RefreshToken{}}
+}
+
+func (r *fakeRefreshRepo) CreateRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[token.ID] = &token
+	return nil
+}
+
+func (r *fakeRefreshRepo) UseRefreshToken(ctx context.Context, id string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[id]
+	if !ok {
+		return nil, errs.ErrRefreshTokenNotFound
+	}
+	if token.UsedAt != nil || token.RevokedAt != nil {
+		return nil, errs.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeRefreshRepo) revokeWhere(match func(t *models.RefreshToken) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, token := range r.tokens {
+		if match(token) && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+}
+
+func (r *fakeRefreshRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	r.revokeWhere(func(t *models.RefreshToken) bool { return t.FamilyID == familyID })
+	return nil
+}
+
+func (r *fakeRefreshRepo) RevokeUserTokens(ctx context.Context, userID int) error {
+	r.revokeWhere(func(t *models.RefreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+type fakeRevocationRepo struct {
+	mu            sync.Mutex
+	tokens        map[string]bool
+	revokedBefore map[int]time.Time
+	versions      map[int]int
+}
+
+func newFakeRevocationRepo() *fakeRevocationRepo {
+	return &fakeRevocationRepo{tokens: map[string]bool{}, revokedBefore: map[int]time.Time{},
+		versions: map[int]int{}}
+}
+
+func (r *fakeRevocationRepo) RevokeToken(ctx context.Context, id string, userID int, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[id] = true
+	return nil
+}
+
+func (r *fakeRevocationRepo) RevokeAllForUser(ctx context.Context, userID int, before time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokedBefore[userID] = before.Truncate(time.Second)
+	r.versions[userID]++
+	return nil
+}
+
+func (r *fakeRevocationRepo) GetTokenVersion(ctx context.Context, userID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.versions[userID], nil
+}
+
+func (r *fakeRevocationRepo) IsTokenRevoked(ctx context.Context, id string, userID int,
+	issuedAt time.Time, version int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	before, ok := r.revokedBefore[userID]
+	return r.tokens[id] || (ok && (before.After(issuedAt) || r.versions[userID] > version)), nil
+}
+
+func (r *fakeRevocationRepo) DeleteExpired(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+type fakeResetRepo struct {
+	mu     sync.Mutex
+	tokens map[string]*fakeResetToken
+}
+
+type fakeResetToken struct {
+	userID    int
+	expiresAt time.Time
+	used      bool
+}
+
+func newFakeResetRepo() *fakeResetRepo {
+	return &fakeResetRepo{tokens: map[string]*fakeResetToken{}}
+}
+
+func (r *fakeResetRepo) CreateResetToken(ctx context.Context, tokenHash string, userID int,
+	expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[tokenHash] = &fakeResetToken{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *fakeResetRepo) UseResetToken(ctx context.Context, tokenHash string, now time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[tokenHash]
+	if !ok || token.used || !token.expiresAt.After(now) {
+		return 0, errs.ErrResetTokenInvalid
+	}
+	token.used = true
+	return token.userID, nil
+}
+
+func (r *fakeResetRepo) InvalidateUserTokens(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.userID == userID {
+			token.used = true
+		}
+	}
+	return nil
+}
+
+type fakeMFARepo struct {
+	mu     sync.Mutex
+	states map[int]*models.MFAState
+}
+
+func newFakeMFARepo() *fakeMFARepo {
+	return &fakeMFARepo{states: map[int]*models.MFAState{}}
+}
+
+func (r *fakeMFARepo) state(userID int) *models.MFAState {
+	state, ok := r.states[userID]
+	if !ok {
+		state = &models.MFAState{}
+		r.states[userID] = state
+	}
+	return state
+}
+
+func (r *fakeMFARepo) GetMFA(ctx context.Context, userID int) (*models.MFAState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *r.state(userID)
+	return &copied, nil
+}
+
+func (r *fakeMFARepo) SetPendingSecret(ctx context.Context, userID int, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state(userID).Secret = secret
+	return nil
+}
+
+func (r *fakeMFARepo) EnableMFA(ctx context.Context, userID int, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	state := r.state(userID)
+	state.EnabledAt = &now
+	state.LastStep = &step
+	return nil
+}
+
+func (r *fakeMFARepo) DisableMFA(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.states, userID)
+	return nil
+}
+
+func (r *fakeMFARepo) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	state := r.state(userID)
+	if state.LastStep != nil && step <= *state.LastStep {
+		return errs.ErrMFACodeInvalid
+	}
+	state.LastStep = &step
+	return nil
+}
+
+func (r *fakeMFARepo) ReplaceRecoveryCodes(ctx context.Context, userID int, codeHashes []string) error {
+	return nil
+}
+
+func (r *fakeMFARepo) UseRecoveryCode(ctx context.Context, userID int, codeHash string) error {
+	return errs.ErrMFACodeInvalid
+}
+
+type fakeAPIKeyRepo struct {
+	mu          sync.Mutex
+	revokedFor  []int
+	createdKeys []models.APIKey
+}
+
+func (r *fakeAPIKeyRepo) CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.createdKeys = append(r.createdKeys, key)
+	return len(r.createdKeys), nil
+}
+
+func (r *fakeAPIKeyRepo) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, string, error) {
+	return nil, "", errs.ErrAPIKeyNotFound
+}
+
+func (r *fakeAPIKeyRepo) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	return nil, nil
+}
+
+func (r *fakeAPIKeyRepo) RevokeAPIKey(ctx context.Context, id int, userID int) error {
+	return errs.ErrAPIKeyNotFound
+}
+
+func (r *fakeAPIKeyRepo) RevokeUserAPIKeys(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.revokedFor = append(r.revokedFor, userID)
+	return nil
+}
+
+func (r *fakeAPIKeyRepo) TouchAPIKey(ctx context.Context, id int, now time.Time) error {
+	return nil
+}
+
+// fakeRoleRepo grants the same permissions to every user.
+type fakeRoleRepo struct {
+	permissions []string
+}
+
+func (r *fakeRoleRepo) HasPermission(ctx context.Context, userID int, permission string) (bool, error) {
+	return slices.Contains(r.permissions, permission), nil
+}
+
+func (r *fakeRoleRepo) GetRoles(ctx context.Context) ([]models.Role, error) {
+	return nil, nil
+}
+
+func (r *fakeRoleRepo) RoleExists(ctx context.Context, name string) (bool, error) {
+	return false, nil
+}
+
+// fakeLoginAttemptRepo keeps one counter per key. Without a database it
+// cannot roll back, so a reservation refused because of a lock still counts.
+type fakeLoginAttemptRepo struct {
+	mu       sync.Mutex
+	attempts map[string]*fakeLoginAttempt
+}
+
+type fakeLoginAttempt struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   time.Time
+}
+
+func newFakeLoginAttemptRepo() *fakeLoginAttemptRepo {
+	return &fakeLoginAttemptRepo{attempts: map[string]*fakeLoginAttempt{}}
+}
+
+func (r *fakeLoginAttemptRepo) RecordFailure(ctx context.Context, key string, now time.Time,
+	window time.Duration) (int, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		attempt = &fakeLoginAttempt{}
+		r.attempts[key] = attempt
+	}
+	if attempt.lastFailureAt.Before(now.Add(-window)) {
+		attempt.failures = 0
+	}
+	attempt.failures++
+	attempt.lastFailureAt = now
+	return attempt.failures, attempt.lockedUntil, nil
+}
+
+func (r *fakeLoginAttemptRepo) LockUntil(ctx context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt, ok := r.attempts[key]; ok && until.After(attempt.lockedUntil) {
+		attempt.lockedUntil = until
+	}
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) ReleaseAttempt(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if attempt, ok := r.attempts[key]; ok {
+		attempt.failures = max(attempt.failures-1, 0)
+		attempt.lockedUntil = time.Time{}
+	}
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) ResetAttempts(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
+
+func (r *fakeLoginAttemptRepo) lock(key string, until time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts[key] = &fakeLoginAttempt{lastFailureAt: time.Now(), lockedUntil: until}
+}
+
+type fakeAuditRepo struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (r *fakeAuditRepo) CreateAuditEntry(ctx context.Context, entry models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+// fakeTransactor runs fn directly; the fakes apply changes immediately.
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type fakeMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *fakeMailer) last() mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.messages) == 0 {
+		return mail.Message{}
+	}
+	return m.messages[len(m.messages)-1]
+}
+
+// authFixture wires an AuthService to in-memory fakes.
+type authFixture struct {
+	service     *AuthService
+	users       *fakeUserRepo
+	refresh     *fakeRefreshRepo
+	revocations *fakeRevocationRepo
+	resets      *fakeResetRepo
+	mfa         *fakeMFARepo
+	apiKeys     *fakeAPIKeyRepo
+	attempts    *fakeLoginAttemptRepo
+	mailer      *fakeMailer
+}
+
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	setupKeys(t)
+	f := &authFixture{
+		users:       newFakeUserRepo(),
+		refresh:     newFakeRefreshRepo(),
+		revocations: newFakeRevocationRepo(),
+		resets:      newFakeResetRepo(),
+		mfa:         newFakeMFARepo(),
+		apiKeys:     &fakeAPIKeyRepo{},
+		attempts:    newFakeLoginAttemptRepo(),
+		mailer:      &fakeMailer{},
+	}
+	guard := NewLoginGuard(f.attempts, &fakeAuditRepo{}, fakeTransactor{}, DefaultUserLoginPolicy,
+		DefaultIPLoginPolicy)
+	f.service = NewAuthService(f.users, f.refresh, f.revocations, f.resets, f.mfa, f.apiKeys, fakeTransactor{},
+		guard, f.mailer, "http://api.test", "http://app.test/reset-password")
+	return f
+}
+
+// addUser stores a user with a real password hash and returns its ID.
+func (f *authFixture) addUser(t *testing.T, user models.User, password string) int {
+	t.Helper()
+	hash, err := hashPasswordForTest(password)
+	if err != nil {
+		t.Fatalf("hashing password: %v", err)
+	}
+	user.Password = hash
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	id, err := f.users.CreateUser(context.Background(), user)
+	if err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+	return id
+}
+
+var (
+	passwordHashes   = map[string]string{}
+	passwordHashesMu sync.Mutex
+)
+
+// hashPasswordForTest caches hashes, since argon2id is deliberately slow.
+func hashPasswordForTest(password string) (string, error) {
+	passwordHashesMu.Lock()
+	defer passwordHashesMu.Unlock()
+	if hash, ok := passwordHashes[password]; ok {
+		return hash, nil
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return "", err
+	}
+	passwordHashes[password] = hash
+	return hash, nil
+}
+
+type fakeOIDCRepo struct {
+	mu         sync.Mutex
+	states     map[string]models.OIDCLoginState
+	identities map[string]models.UserIdentity
+}
+
+func newFakeOIDCRepo() *fakeOIDCRepo {
+	return &fakeOIDCRepo{states: map[string]models.OIDCLoginState{}, identities: map[string]models.UserIdentity{}}
+}
+
+func (r *fakeOIDCRepo) CreateLoginState(ctx context.Context, state models.OIDCLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[state.State] = state
+	return nil
+}
+
+func (r *fakeOIDCRepo) UseLoginState(ctx context.Context, state string, now time.Time) (*models.OIDCLoginState,
+	error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loginState, ok := r.states[state]
+	delete(r.states, state)
+	if !ok || !loginState.ExpiresAt.After(now) {
+		return nil, errs.ErrOIDCStateInvalid
+	}
+	return &loginState, nil
+}
+
+func (r *fakeOIDCRepo) GetUserIDByIdentity(ctx context.Context, provider, subject string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	identity, ok := r.identities[provider+"|"+subject]
+	if !ok {
+		return 0, errs.ErrIdentityNotFound
+	}
+	return identity.UserID, nil
+}
+
+func (r *fakeOIDCRepo) CreateIdentity(ctx context.Context, identity models.UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identities[identity.Provider+"|"+identity.Subject] = identity
+	return nil
+}
+
+// fakeProvider accepts an exchange only with the verifier and nonce that
+// belong to the last authorization URL it built.
+type fakeProvider struct {
+	identity  *oidc.Identity
+	challenge string
+	nonce     string
+}
+
+func (p *fakeProvider) Name() string {
+	return "mock"
+}
+
+func (p *fakeProvider) AuthCodeURL(state, nonce, codeChallenge string) string {
+	p.nonce = nonce
+	p.challenge = codeChallenge
+	return "http://idp.test/authorize?state=" + url.QueryEscape(state)
+}
+
+func (p *fakeProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*oidc.Identity, error) {
+	if code != "good-code" || oidc.CodeChallengeS256(codeVerifier) != p.challenge || nonce != p.nonce {
+		return nil, oidc.ErrExchangeFailed
+	}
+	identity := *p.identity
+	return &identity, nil
+}
+
+type fakeLotRepo struct {
+	mu        sync.Mutex
+	nextID    int
+	lots      map[int]*models.LotResponse
+	history   []models.LotStatusChange
+	revisions []models.LotRevision
+}
+
+func newFakeLotRepo() *fakeLotRepo {
+	return &fakeLotRepo{lots: map[int]*models.LotResponse{}}
+}
+
+func (r *fakeLotRepo) CreateLot(ctx context.Context, lot models.LotCreate) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	status := lot.Status
+	if status == "" {
+		status = models.LotStatusActive
+	}
+	r.lots[r.nextID] = &models.LotResponse{
+		ID:                r.nextID,
+		Title:             lot.Title,
+		Description:       lot.Description,
+		StartPrice:        lot.StartPrice,
+		CurrentPrice:      lot.CurrentPrice,
+		StartTime:         lot.StartTime,
+		EndTime:           lot.EndTime,
+		CreatedAt:         lot.CreatedAt,
+		UserID:            lot.UserID,
+		Status:            status,
+		IncrementSchedule: lot.IncrementSchedule,
+		ReservePrice:      lot.ReservePrice,
+		BuyNowPrice:       lot.BuyNowPrice,
+		AuctionType:       lot.AuctionType,
+		FloorPrice:        lot.FloorPrice,
+		PriceDecrement:    lot.PriceDecrement,
+		DecrementSeconds:  lot.DecrementSeconds,
+	}
+	return r.nextID, nil
+}
+
+func (r *fakeLotRepo) GetLots(ctx context.Context) ([]models.LotResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var lots []models.LotResponse
+	for _, lot := range r.lots {
+		if lot.Status == models.LotStatusActive {
+			lots = append(lots, *lot)
+		}
+	}
+	return lots, nil
+}
+
+func (r *fakeLotRepo) GetLotByID(ctx context.Context, id int) (*models.LotResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lot, ok := r.lots[id]
+	if !ok {
+		return nil, errs.ErrFoundLot
+	}
+	copied := *lot
+	return &copied, nil
+}
+
+func (r *fakeLotRepo) GetLotForUpdate(ctx context.Context, id int) (*models.LotResponse, error) {
+	return r.GetLotByID(ctx, id)
+}
+
+func (r *fakeLotRepo) update(lotID int, fn func(lot *models.LotResponse) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lot, ok := r.lots[lotID]
+	if !ok {
+		return errs.ErrFoundLot
+	}
+	return fn(lot)
+}
+
+func (r *fakeLotRepo) UpdateLotPrice(ctx context.Context, lotID int, newPrice int) error {
+	return r.update(lotID, func(lot *models.LotResponse) error {
+		lot.CurrentPrice = newPrice
+		return nil
+	})
+}
+
+func (r *fakeLotRepo) RaiseLotPrice(ctx context.Context, lotID int, newPrice int) error {
+	return r.update(lotID, func(lot *models.LotResponse) error {
+		if lot.CurrentPrice >= newPrice {
+			return errs.ErrBidConflict
+		}
+		lot.CurrentPrice = newPrice
+		return nil
+	})
+}
+
+func (r *fakeLotRepo) ExtendLotEndTime(ctx context.Context, lotID int, extension time.Duration) (time.Time,
+	error) {
+	var endTime time.Time
+	err := r.update(lotID, func(lot *models.LotResponse) error {
+		lot.EndTime = lot.EndTime.Add(extension)
+		lot.ExtensionCount++
+		endTime = lot.EndTime
+		return nil
+	})
+	return endTime, err
+}
+
+func (r *fakeLotRepo) GetExpiredLotIDs(ctx context.Context, now time.Time) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []int
+	for id, lot := range r.lots {
+		if lot.Status == models.LotStatusActive && !lot.EndTime.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *fakeLotRepo) UpdateLotStatus(ctx context.Context, change models.LotStatusChange) error {
+	return r.update(change.LotID, func(lot *models.LotResponse) error {
+		if lot.Status != change.FromStatus {
+			return errs.ErrFoundLot
+		}
+		lot.Status = change.ToStatus
+		r.history = append(r.history, change)
+		return nil
+	})
+}
+
+func (r *fakeLotRepo) GetStatusHistory(ctx context.Context, lotID int) ([]models.LotStatusChange, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var history []models.LotStatusChange
+	for _, change := range r.history {
+		if change.LotID == lotID {
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
+
+func (r *fakeLotRepo) UpdateLotDetails(ctx context.Context, lotID int, update models.LotUpdate) error {
+	return r.update(lotID, func(lot *models.LotResponse) error {
+		lot.Title = update.Title
+		lot.Description = update.Description
+		lot.StartPrice = update.StartPrice
+		lot.CurrentPrice = update.CurrentPrice
+		lot.EndTime = update.EndTime
+		return nil
+	})
+}
+
+func (r *fakeLotRepo) CreateLotRevision(ctx context.Context, revision models.LotRevision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	revision.ID = len(r.revisions) + 1
+	r.revisions = append(r.revisions, revision)
+	return nil
+}
+
+func (r *fakeLotRepo) GetLotRevisions(ctx context.Context, lotID int) ([]models.LotRevision, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revisions []models.LotRevision
+	for _, revision := range r.revisions {
+		if revision.LotID == lotID {
+			revisions = append(revisions, revision)
+		}
+	}
+	return revisions, nil
+}
+
+// fakeBidRepo orders bids like the Postgres queries: by amount, then by when
+// they were placed. Each write happens a millisecond after the previous one.
+type fakeBidRepo struct {
+	mu   sync.Mutex
+	bids []models.Bid
+	seq  int
+}
+
+var fakeBidEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (r *fakeBidRepo) CreateBid(ctx context.Context, bid models.BidCreate) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := len(r.bids) + 1
+	r.bids = append(r.bids, models.Bid{
+		ID:        id,
+		LotID:     bid.LotID,
+		UserID:    bid.UserID,
+		Amount:    bid.Amount,
+		CreatedAt: r.now(),
+	})
+	return id, nil
+}
+
+func (r *fakeBidRepo) now() time.Time {
+	r.seq++
+	return fakeBidEpoch.Add(time.Duration(r.seq) * time.Millisecond)
+}
+
+func (r *fakeBidRepo) GetMyBids(ctx context.Context, userID int) ([]models.Bid, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var bids []models.Bid
+	for _, bid := range r.bids {
+		if bid.UserID == userID {
+			bids = append(bids, bid)
+		}
+	}
+	return bids, nil
+}
+
+func (r *fakeBidRepo) GetHighestBid(ctx context.Context, lotID int) (*models.Bid, error) {
+	bids, err := r.GetTopBids(ctx, lotID, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(bids) == 0 {
+		return nil, errs.ErrNoBids
+	}
+	return &bids[0], nil
+}
+
+func (r *fakeBidRepo) GetTopBids(ctx context.Context, lotID int, limit int) ([]models.Bid, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var bids []models.Bid
+	for _, bid := range r.bids {
+		if bid.LotID == lotID {
+			bids = append(bids, bid)
+		}
+	}
+	sort.SliceStable(bids, func(i, j int) bool {
+		if bids[i].Amount != bids[j].Amount {
+			return bids[i].Amount > bids[j].Amount
+		}
+		return bids[i].CreatedAt.Before(bids[j].CreatedAt)
+	})
+	if len(bids) > limit {
+		bids = bids[:limit]
+	}
+	return bids, nil
+}
+
+func (r *fakeBidRepo) GetUserBidForLot(ctx context.Context, lotID int, userID int) (*models.Bid, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *models.Bid
+	for i := range r.bids {
+		bid := r.bids[i]
+		if bid.LotID == lotID && bid.UserID == userID && (latest == nil || bid.CreatedAt.After(latest.CreatedAt)) {
+			latest = &bid
+		}
+	}
+	if latest == nil {
+		return nil, errs.ErrNoBids
+	}
+	return latest, nil
+}
+
+func (r *fakeBidRepo) UpdateBidAmount(ctx context.Context, bidID int, amount int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.bids {
+		if r.bids[i].ID == bidID {
+			r.bids[i].Amount = amount
+			r.bids[i].CreatedAt = r.now()
+		}
+	}
+	return nil
+}
+
+func (r *fakeBidRepo) GetLotBidderIDs(ctx context.Context, lotID int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seen := map[int]bool{}
+	var ids []int
+	for _, bid := range r.bids {
+		if bid.LotID == lotID && !seen[bid.UserID] {
+			seen[bid.UserID] = true
+			ids = append(ids, bid.UserID)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+type fakeProxyBidRepo struct {
+	mu      sync.Mutex
+	proxies map[[2]int]models.ProxyBid
+}
+
+func newFakeProxyBidRepo() *fakeProxyBidRepo {
+	return &fakeProxyBidRepo{proxies: map[[2]int]models.ProxyBid{}}
+}
+
+func (r *fakeProxyBidRepo) UpsertProxyBid(ctx context.Context, proxyBid models.ProxyBid) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := [2]int{proxyBid.LotID, proxyBid.UserID}
+	if existing, ok := r.proxies[key]; !ok || existing.MaxAmount < proxyBid.MaxAmount {
+		r.proxies[key] = proxyBid
+	}
+	return nil
+}
+
+func (r *fakeProxyBidRepo) GetProxyBid(ctx context.Context, lotID int, userID int) (*models.ProxyBid, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	proxyBid, ok := r.proxies[[2]int{lotID, userID}]
+	if !ok {
+		return nil, errs.ErrProxyBidNotFound
+	}
+	return &proxyBid, nil
+}
+
+type fakeWinnerRepo struct {
+	mu      sync.Mutex
+	winners map[int]models.Winner
+}
+
+func newFakeWinnerRepo() *fakeWinnerRepo {
+	return &fakeWinnerRepo{winners: map[int]models.Winner{}}
+}
+
+func (r *fakeWinnerRepo) CreateWinner(ctx context.Context, winner models.Winner) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.winners[winner.LotID]; ok {
+		return errs.ErrLotClosed
+	}
+	r.winners[winner.LotID] = winner
+	return nil
+}
+
+func (r *fakeWinnerRepo) GetWinnerByLotID(ctx context.Context, lotID int) (*models.Winner, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	winner, ok := r.winners[lotID]
+	if !ok {
+		return nil, errs.ErrWinnerNotFound
+	}
+	return &winner, nil
+}
+
+// auctionFixture wires the bidding, lot and closing services to shared fakes.
+// Users 1 (the seller), 2 (bob) and 3 (carol) exist and have verified their email.
+type auctionFixture struct {
+	lots    *fakeLotRepo
+	bids    *fakeBidRepo
+	proxies *fakeProxyBidRepo
+	winners *fakeWinnerRepo
+	users   *fakeUserRepo
+	mailer  *fakeMailer
+
+	bidService *BidService
+	lotService *LotService
+	closer     *AuctionCloser
+}
+
+const sellerID = 1
+
+func newAuctionFixture(t *testing.T, antiSnipe AntiSniping) *auctionFixture {
+	t.Helper()
+	f := &auctionFixture{
+		lots:    newFakeLotRepo(),
+		bids:    &fakeBidRepo{},
+		proxies: newFakeProxyBidRepo(),
+		winners: newFakeWinnerRepo(),
+		users:   newFakeUserRepo(),
+		mailer:  &fakeMailer{},
+	}
+	for _, name := range []string{"seller", "bob", "carol"} {
+		f.users.CreateUser(context.Background(), models.User{Username: name, Email: name + "@example.com",
+			Role: models.RoleUser, EmailVerified: true})
+	}
+	f.bidService = NewBidService(f.bids, f.lots, f.proxies, f.users, fakeTransactor{}, DefaultIncrementSchedule,
+		antiSnipe)
+	f.lotService = NewLotService(f.lots, f.users, f.winners, f.bids, fakeTransactor{}, DefaultIncrementSchedule,
+		f.mailer)
+	f.closer = NewAuctionCloser(f.lots, f.bids, f.winners, fakeTransactor{}, time.Minute)
+	return f
+}
+
+// addLot stores an active lot that started an hour ago and ends in a day,
+// unless lot says otherwise.
+func (f *auctionFixture) addLot(t *testing.T, lot models.LotCreate) int {
+	t.Helper()
+	if lot.Title == "" {
+		lot.Title = "Vintage camera"
+	}
+	if lot.Description == "" {
+		lot.Description = "A working film camera from 1970."
+	}
+	if lot.CurrentPrice == 0 {
+		lot.CurrentPrice = lot.StartPrice
+	}
+	if lot.StartTime.IsZero() {
+		lot.StartTime = time.Now().Add(-time.Hour)
+	}
+	if lot.EndTime.IsZero() {
+		lot.EndTime = time.Now().Add(24 * time.Hour)
+	}
+	if lot.AuctionType == "" {
+		lot.AuctionType = models.AuctionTypeEnglish
+	}
+	lot.UserID = sellerID
+	id, err := f.lots.CreateLot(context.Background(), lot)
+	if err != nil {
+		t.Fatalf("creating lot: %v", err)
+	}
+	return id
+}
+
+func (f *auctionFixture) lot(t *testing.T, lotID int) *models.LotResponse {
+	t.Helper()
+	lot, err := f.lots.GetLotByID(context.Background(), lotID)
+	if err != nil {
+		t.Fatalf("loading lot: %v", err)
+	}
+	return lot
+}
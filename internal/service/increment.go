@@ -0,0 +1,59 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"encoding/json"
+)
+
+var DefaultIncrementSchedule = models.IncrementSchedule{
+	{From: 0, Increment: 10},
+	{From: 1000, Increment: 50},
+	{From: 10000, Increment: 100},
+}
+
+// ParseIncrementSchedule reads a JSON schedule such as
+// [{"from":0,"increment":10},{"from":1000,"increment":50}].
+func ParseIncrementSchedule(raw string) (models.IncrementSchedule, error) {
+	var schedule models.IncrementSchedule
+	if err := json.Unmarshal([]byte(raw), &schedule); err != nil {
+		return nil, errs.ErrInvalidIncrements
+	}
+	if err := validateIncrementSchedule(schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+func validateIncrementSchedule(schedule models.IncrementSchedule) error {
+	if len(schedule) == 0 || schedule[0].From != 0 {
+		return errs.ErrInvalidIncrements
+	}
+	for i, step := range schedule {
+		if step.Increment <= 0 {
+			return errs.ErrInvalidIncrements
+		}
+		if i > 0 && step.From <= schedule[i-1].From {
+			return errs.ErrInvalidIncrements
+		}
+	}
+	return nil
+}
+
+func incrementSchedule(lot *models.LotResponse, global models.IncrementSchedule) models.IncrementSchedule {
+	if len(lot.IncrementSchedule) > 0 {
+		return lot.IncrementSchedule
+	}
+	return global
+}
+
+// nextMinimumBid is the lowest acceptable bid. The start price counts as the
+// opening price, so the first bid must clear it by an increment just like
+// every later bid clears the current price. Sealed lots only need the start
+// price, since no current price is shown.
+func nextMinimumBid(lot *models.LotResponse, global models.IncrementSchedule) int {
+	if isSealed(lot) {
+		return lot.StartPrice
+	}
+	return lot.CurrentPrice + incrementSchedule(lot, global).Increment(lot.CurrentPrice)
+}
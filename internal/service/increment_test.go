@@ -0,0 +1,57 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"testing"
+)
+
+func TestParseIncrementSchedule(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		wantErr error
+	}{
+		{name: "valid", raw: `[{"from":0,"increment":10},{"from":1000,"increment":50}]`},
+		{name: "not json", raw: `10`, wantErr: errs.ErrInvalidIncrements},
+		{name: "empty", raw: `[]`, wantErr: errs.ErrInvalidIncrements},
+		{name: "does not start at zero", raw: `[{"from":100,"increment":10}]`, wantErr: errs.ErrInvalidIncrements},
+		{name: "zero increment", raw: `[{"from":0,"increment":0}]`, wantErr: errs.ErrInvalidIncrements},
+		{name: "unordered", raw: `[{"from":0,"increment":10},{"from":1000,"increment":50},{"from":500,"increment":20}]`,
+			wantErr: errs.ErrInvalidIncrements},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseIncrementSchedule(tt.raw); err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNextMinimumBid(t *testing.T) {
+	perLot := models.IncrementSchedule{{From: 0, Increment: 5}, {From: 200, Increment: 25}}
+	tests := []struct {
+		name string
+		lot  models.LotResponse
+		want int
+	}{
+		{name: "lowest band", lot: models.LotResponse{CurrentPrice: 100}, want: 110},
+		{name: "just below a band", lot: models.LotResponse{CurrentPrice: 999}, want: 1009},
+		{name: "band boundary", lot: models.LotResponse{CurrentPrice: 1000}, want: 1050},
+		{name: "top band", lot: models.LotResponse{CurrentPrice: 25000}, want: 25100},
+		{name: "per-lot schedule", lot: models.LotResponse{CurrentPrice: 100, IncrementSchedule: perLot}, want: 105},
+		{name: "per-lot upper band", lot: models.LotResponse{CurrentPrice: 200, IncrementSchedule: perLot},
+			want: 225},
+		{name: "sealed lot accepts the start price",
+			lot:  models.LotResponse{StartPrice: 100, CurrentPrice: 100, AuctionType: models.AuctionTypeSealedFirstPrice},
+			want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextMinimumBid(&tt.lot, DefaultIncrementSchedule); got != tt.want {
+				t.Fatalf("want %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
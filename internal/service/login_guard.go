@@ -0,0 +1,160 @@
+package service
+
+import (
+	"auction/internal/models"
+	"auction/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoginPolicy throttles failed logins for one key. The first FreeAttempts
+// failures cost nothing; each further failure blocks the key for BaseDelay,
+// doubling up to MaxDelay. At LockoutAfter failures the key is locked for
+// LockoutDuration, which is also how long a series of failures is remembered.
+type LoginPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+var (
+	DefaultUserLoginPolicy = LoginPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+	}
+	// An address may be shared by many users, so it gets more attempts.
+	DefaultIPLoginPolicy = LoginPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: 30 * time.Minute,
+	}
+)
+
+func (p LoginPolicy) delay(failures int) time.Duration {
+	if failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// LoginGuard tracks failed logins per username and per client address.
+//
+// Every attempt is counted as a failure before the credentials are checked,
+// and the count and lock check happen under a row lock. A burst of parallel
+// attempts is therefore throttled exactly like sequential ones instead of
+// all passing a check made before any failure was recorded.
+type LoginGuard struct {
+	attempts   repository.LoginAttemptRepository
+	audit      repository.AuditRepository
+	tx         repository.Transactor
+	userPolicy LoginPolicy
+	ipPolicy   LoginPolicy
+}
+
+func NewLoginGuard(attempts repository.LoginAttemptRepository, audit repository.AuditRepository,
+	tx repository.Transactor, userPolicy, ipPolicy LoginPolicy) *LoginGuard {
+	return &LoginGuard{
+		attempts:   attempts,
+		audit:      audit,
+		tx:         tx,
+		userPolicy: userPolicy,
+		ipPolicy:   ipPolicy,
+	}
+}
+
+// errAttemptLocked rolls back a reservation made for a locked key.
+var errAttemptLocked = errors.New("login attempt locked")
+
+// Reserve counts an attempt by username from ip as failed. When either key is
+// locked nothing is counted and Reserve returns how long the caller must
+// wait. A reserved attempt that succeeds is settled with RecordSuccess or
+// Release.
+func (g *LoginGuard) Reserve(ctx context.Context, username, ip string, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	err := g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		keys := []struct {
+			key    string
+			policy LoginPolicy
+			event  string
+		}{
+			{userKey(username), g.userPolicy, models.AuditEventUserLockout},
+			{ipKey(ip), g.ipPolicy, models.AuditEventIPLockout},
+		}
+		for _, k := range keys {
+			failures, lockedUntil, err := g.attempts.RecordFailure(ctx, k.key, now, k.policy.LockoutDuration)
+			if err != nil {
+				return err
+			}
+			if lockedUntil.After(now) {
+				wait = lockedUntil.Sub(now)
+				return errAttemptLocked
+			}
+			if delay := k.policy.delay(failures); delay > 0 {
+				if err := g.attempts.LockUntil(ctx, k.key, now.Add(delay)); err != nil {
+					return err
+				}
+			}
+			if failures == k.policy.LockoutAfter {
+				err := g.audit.CreateAuditEntry(ctx, models.AuditEntry{
+					Event:    k.event,
+					Username: username,
+					IP:       ip,
+					Details:  fmt.Sprintf("%d failed logins, locked for %s", failures, k.policy.LockoutDuration),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == errAttemptLocked {
+		return wait, nil
+	}
+	return 0, err
+}
+
+// RecordSuccess clears the username's failures. The address only gets the
+// reserved attempt back and loses the delay counting it applied, so one valid
+// account cannot be used to reset it.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, username, ip string) error {
+	if err := g.attempts.ResetAttempts(ctx, userKey(username)); err != nil {
+		return err
+	}
+	return g.attempts.ReleaseAttempt(ctx, ipKey(ip))
+}
+
+// Release takes back a reserved attempt, and the delay counting it applied,
+// whose credentials were correct but which did not complete a login, such as
+// a password followed by an MFA challenge.
+func (g *LoginGuard) Release(ctx context.Context, username, ip string) error {
+	if err := g.attempts.ReleaseAttempt(ctx, userKey(username)); err != nil {
+		return err
+	}
+	return g.attempts.ReleaseAttempt(ctx, ipKey(ip))
+}
+
+func userKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -2,46 +2,76 @@ package service
 
 import (
 	"auction/internal/errs"
+	"auction/internal/mail"
 	"auction/internal/models"
 	"auction/internal/repository"
 	"context"
+	"fmt"
+	"log"
+	"strings"
 	"time"
 )
 
+// maxReasonLength matches lot_status_history.reason.
+const maxReasonLength = 255
+
 type LotService struct {
-	lotRepo  repository.LotRepository
-	userRepo repository.UserRepository
+	lotRepo    repository.LotRepository
+	userRepo   repository.UserRepository
+	winnerRepo repository.WinnerRepository
+	bidRepo    repository.BidRepository
+	tx         repository.Transactor
+	increments models.IncrementSchedule
+	mailer     mail.Sender
 }
 
-func NewLotService(lotRepo *repository.PostgresLotRepository, userRepo repository.UserRepository) *LotService {
+func NewLotService(lotRepo repository.LotRepository, userRepo repository.UserRepository,
+	winnerRepo repository.WinnerRepository, bidRepo repository.BidRepository, tx repository.Transactor,
+	increments models.IncrementSchedule, mailer mail.Sender) *LotService {
 	return &LotService{
-		lotRepo:  lotRepo,
-		userRepo: userRepo,
+		lotRepo:    lotRepo,
+		userRepo:   userRepo,
+		winnerRepo: winnerRepo,
+		bidRepo:    bidRepo,
+		tx:         tx,
+		increments: increments,
+		mailer:     mailer,
 	}
 }
 
 func (s *LotService) CreateLot(ctx context.Context, userID int, lot models.Lot) (lotID int, err error) {
-	role, err := s.userRepo.GetUserRole(ctx, userID)
-	if err != nil {
+	if err := checkEmailVerified(ctx, s.userRepo, userID); err != nil {
 		return 0, err
 	}
 
-	if role != "user" {
-		return 0, errs.ErrNoAccess
-	}
-
 	if err := s.validateLot(lot); err != nil {
 		return 0, err
 	}
 
+	auctionType := lot.AuctionType
+	if auctionType == "" {
+		auctionType = models.AuctionTypeEnglish
+	}
+	startTime := lot.StartTime
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
 	lotData := models.LotCreate{
-		Title:        lot.Title,
-		Description:  lot.Description,
-		StartPrice:   lot.StartPrice,
-		CurrentPrice: lot.StartPrice,
-		EndTime:      lot.EndTime,
-		UserID:       userID,
-		CreatedAt:    time.Now(),
+		Title:             lot.Title,
+		Description:       lot.Description,
+		StartPrice:        lot.StartPrice,
+		CurrentPrice:      lot.StartPrice,
+		StartTime:         startTime,
+		EndTime:           lot.EndTime,
+		UserID:            userID,
+		CreatedAt:         time.Now(),
+		IncrementSchedule: lot.IncrementSchedule,
+		ReservePrice:      lot.ReservePrice,
+		BuyNowPrice:       lot.BuyNowPrice,
+		AuctionType:       auctionType,
+		FloorPrice:        lot.FloorPrice,
+		PriceDecrement:    lot.PriceDecrement,
+		DecrementSeconds:  lot.DecrementSeconds,
 	}
 
 	lotID, err = s.lotRepo.CreateLot(ctx, lotData)
@@ -57,6 +87,9 @@ func (s *LotService) GetLots(ctx context.Context) ([]models.LotResponse, error)
 	if err != nil {
 		return nil, err
 	}
+	for i := range lots {
+		s.fillDerived(&lots[i])
+	}
 	return lots, nil
 }
 
@@ -68,9 +101,117 @@ func (s *LotService) GetLotByID(ctx context.Context, lotID int) (*models.LotResp
 	if err != nil {
 		return nil, err
 	}
+	s.fillDerived(lot)
+	if lot.Status == models.LotStatusClosed {
+		winner, err := s.winnerRepo.GetWinnerByLotID(ctx, lotID)
+		if err != nil && err != errs.ErrWinnerNotFound {
+			return nil, err
+		}
+		lot.Winner = winner
+	}
 	return lot, nil
 }
 
+func (s *LotService) fillDerived(lot *models.LotResponse) {
+	lot.NextMinimumBid = nextMinimumBid(lot, s.increments)
+	lot.ReserveMet = lot.CurrentPrice >= lot.ReservePrice
+	if lot.CurrentPrice >= lot.BuyNowPrice {
+		lot.BuyNowPrice = 0
+	}
+	if isSealed(lot) && lot.Status == models.LotStatusActive {
+		lot.CurrentPrice = 0
+		lot.ReserveMet = false
+	}
+	if lot.AuctionType == models.AuctionTypeDutch && lot.Status == models.LotStatusActive {
+		lot.CurrentPrice = dutchPrice(lot, time.Now())
+		lot.NextMinimumBid = 0
+	}
+}
+
+// BuyNow sells the lot at its buy-now price and closes it.
+func (s *LotService) BuyNow(ctx context.Context, userID int, lotID int) (*models.Winner, error) {
+	buyNowPrice := func(lot *models.LotResponse, now time.Time) (int, error) {
+		if lot.BuyNowPrice == 0 || lot.CurrentPrice >= lot.BuyNowPrice {
+			return 0, errs.ErrBuyNowUnavailable
+		}
+		return lot.BuyNowPrice, nil
+	}
+	return s.sellLot(ctx, userID, lotID, models.LotReasonBuyNow, buyNowPrice)
+}
+
+// AcceptPrice sells a Dutch lot at its current descending price.
+func (s *LotService) AcceptPrice(ctx context.Context, userID int, lotID int) (*models.Winner, error) {
+	currentPrice := func(lot *models.LotResponse, now time.Time) (int, error) {
+		if lot.AuctionType != models.AuctionTypeDutch {
+			return 0, errs.ErrNotDutchAuction
+		}
+		return dutchPrice(lot, now), nil
+	}
+	return s.sellLot(ctx, userID, lotID, models.LotReasonPriceAccepted, currentPrice)
+}
+
+// sellLot closes the lot with userID as winner at the price chosen by priceFn.
+// The lot row is locked for the whole sale, so a competing bid either
+// completes first or sees the lot closed.
+func (s *LotService) sellLot(ctx context.Context, userID int, lotID int, reason string,
+	priceFn func(lot *models.LotResponse, now time.Time) (int, error)) (*models.Winner, error) {
+	if lotID <= 0 {
+		return nil, errs.ErrInvalidLotID
+	}
+	if err := checkEmailVerified(ctx, s.userRepo, userID); err != nil {
+		return nil, err
+	}
+	var winner *models.Winner
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		lot, err := s.lotRepo.GetLotForUpdate(ctx, lotID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := checkLotOpen(lot, now); err != nil {
+			return err
+		}
+		if lot.UserID == userID {
+			return errs.ErrCannotBidOnOwnLot
+		}
+		price, err := priceFn(lot, now)
+		if err != nil {
+			return err
+		}
+		_, err = s.bidRepo.CreateBid(ctx, models.BidCreate{
+			LotID:  lotID,
+			UserID: userID,
+			Amount: price,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.lotRepo.UpdateLotPrice(ctx, lotID, price); err != nil {
+			return err
+		}
+		winner = &models.Winner{
+			LotID:   lotID,
+			UserID:  userID,
+			Amount:  price,
+			WinDate: now,
+		}
+		if err := s.winnerRepo.CreateWinner(ctx, *winner); err != nil {
+			return err
+		}
+		return s.lotRepo.UpdateLotStatus(ctx, models.LotStatusChange{
+			LotID:      lotID,
+			FromStatus: lot.Status,
+			ToStatus:   models.LotStatusClosed,
+			ActorID:    &userID,
+			Reason:     reason,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return winner, nil
+}
+
 func (s *LotService) validateLot(lot models.Lot) error {
 	now := time.Now()
 	if len(lot.Title) < 3 {
@@ -89,19 +230,334 @@ func (s *LotService) validateLot(lot models.Lot) error {
 		return errs.ErrEmptyEndTime
 	}
 
+	if !lot.StartTime.IsZero() && !lot.StartTime.Before(lot.EndTime) {
+		return errs.ErrInvalidStartTime
+	}
+
+	if lot.ReservePrice < 0 || (lot.ReservePrice > 0 && lot.ReservePrice <= lot.StartPrice) {
+		return errs.ErrInvalidReservePrice
+	}
+
+	if lot.BuyNowPrice < 0 || (lot.BuyNowPrice > 0 &&
+		(lot.BuyNowPrice <= lot.StartPrice || lot.BuyNowPrice < lot.ReservePrice)) {
+		return errs.ErrInvalidBuyNowPrice
+	}
+
+	switch lot.AuctionType {
+	case "", models.AuctionTypeEnglish:
+	case models.AuctionTypeSealedFirstPrice, models.AuctionTypeSealedSecondPrice:
+		if lot.BuyNowPrice > 0 {
+			return errs.ErrInvalidBuyNowPrice
+		}
+	case models.AuctionTypeDutch:
+		if !validateDutchLot(lot) {
+			return errs.ErrInvalidDutchLot
+		}
+	default:
+		return errs.ErrInvalidAuctionType
+	}
+
+	if len(lot.IncrementSchedule) > 0 {
+		if err := validateIncrementSchedule(lot.IncrementSchedule); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (s *LotService) DeleteLot(ctx context.Context, lotID int, userID int) error {
+// CancelLot lets the seller withdraw a lot nobody has bid on yet.
+func (s *LotService) CancelLot(ctx context.Context, sellerID int, lotID int, reason string) error {
+	if reason == "" {
+		reason = models.LotReasonCancelled
+	}
+	return s.cancelLot(ctx, sellerID, lotID, reason, func(ctx context.Context, lot *models.LotResponse) error {
+		if lot.UserID != sellerID {
+			return errs.ErrNotLotOwner
+		}
+		bids, err := s.bidRepo.GetTopBids(ctx, lotID, 1)
+		if err != nil {
+			return err
+		}
+		if len(bids) > 0 {
+			return errs.ErrLotHasBids
+		}
+		return nil
+	})
+}
+
+// DeleteLot cancels any open lot on behalf of an administrator. The lot and
+// its bids are kept, so the auction history stays intact.
+func (s *LotService) DeleteLot(ctx context.Context, adminID int, lotID int, reason string) error {
+	if reason == "" {
+		reason = models.LotReasonRemovedByAdmin
+	}
+	return s.cancelLot(ctx, adminID, lotID, reason, nil)
+}
+
+func (s *LotService) cancelLot(ctx context.Context, actorID int, lotID int, reason string,
+	check func(ctx context.Context, lot *models.LotResponse) error) error {
 	if lotID <= 0 {
 		return errs.ErrInvalidLotID
 	}
-	userRole, err := s.userRepo.GetUserRole(ctx, userID)
+	if len(reason) > maxReasonLength {
+		return errs.ErrReasonTooLong
+	}
+	var cancelled *models.LotResponse
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		lot, err := s.lotRepo.GetLotForUpdate(ctx, lotID)
+		if err != nil {
+			return err
+		}
+		// A lot that has not started yet can still be withdrawn.
+		if err := checkLotOpen(lot, time.Now()); err != nil && err != errs.ErrLotNotStarted {
+			return err
+		}
+		if check != nil {
+			if err := check(ctx, lot); err != nil {
+				return err
+			}
+		}
+		cancelled = lot
+		return s.lotRepo.UpdateLotStatus(ctx, models.LotStatusChange{
+			LotID:      lotID,
+			FromStatus: lot.Status,
+			ToStatus:   models.LotStatusCancelled,
+			ActorID:    &actorID,
+			Reason:     reason,
+		})
+	})
 	if err != nil {
 		return err
 	}
-	if userRole != "admin" {
-		return errs.ErrAdminAccessDenied
+	s.notifyBidders(ctx, cancelled, nil, reason)
+	return nil
+}
+
+// EndLotEarly closes the lot now and sells it to the current high bidder at
+// the price the closer would have charged.
+func (s *LotService) EndLotEarly(ctx context.Context, sellerID int, lotID int) (*models.Winner, error) {
+	if lotID <= 0 {
+		return nil, errs.ErrInvalidLotID
+	}
+	var ended *models.LotResponse
+	var winner *models.Winner
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		lot, err := s.lotRepo.GetLotForUpdate(ctx, lotID)
+		if err != nil {
+			return err
+		}
+		if lot.UserID != sellerID {
+			return errs.ErrNotLotOwner
+		}
+		now := time.Now()
+		if err := checkLotOpen(lot, now); err != nil {
+			return err
+		}
+		bids, err := s.bidRepo.GetTopBids(ctx, lotID, 2)
+		if err != nil {
+			return err
+		}
+		if len(bids) == 0 {
+			return errs.ErrNoBids
+		}
+		if bids[0].Amount < lot.ReservePrice {
+			return errs.ErrReserveNotMet
+		}
+
+		price := settlementPrice(lot, bids)
+		if err := s.lotRepo.UpdateLotPrice(ctx, lotID, price); err != nil {
+			return err
+		}
+		winner = &models.Winner{
+			LotID:   lotID,
+			UserID:  bids[0].UserID,
+			Amount:  price,
+			WinDate: now,
+		}
+		if err := s.winnerRepo.CreateWinner(ctx, *winner); err != nil {
+			return err
+		}
+		ended = lot
+		return s.lotRepo.UpdateLotStatus(ctx, models.LotStatusChange{
+			LotID:      lotID,
+			FromStatus: lot.Status,
+			ToStatus:   models.LotStatusClosed,
+			ActorID:    &sellerID,
+			Reason:     models.LotReasonEndedBySeller,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.notifyBidders(ctx, ended, winner, models.LotReasonEndedBySeller)
+	return winner, nil
+}
+
+// UpdateLot applies the seller's edits and records the previous version.
+// Before the first bid the title, description, start price and end time may
+// all change; afterwards bidders have committed to the lot as described, so
+// the description may only be extended. An edit that changes nothing records
+// no revision.
+func (s *LotService) UpdateLot(ctx context.Context, sellerID int,
+	req models.UpdateLotRequest) (*models.LotResponse, error) {
+	if req.LotID <= 0 {
+		return nil, errs.ErrInvalidLotID
+	}
+	if req.Title == nil && req.Description == nil && req.StartPrice == nil && req.EndTime == nil {
+		return nil, errs.ErrNothingToUpdate
+	}
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		lot, err := s.lotRepo.GetLotForUpdate(ctx, req.LotID)
+		if err != nil {
+			return err
+		}
+		if lot.UserID != sellerID {
+			return errs.ErrNotLotOwner
+		}
+		if err := checkLotOpen(lot, time.Now()); err != nil && err != errs.ErrLotNotStarted {
+			return err
+		}
+		bids, err := s.bidRepo.GetTopBids(ctx, req.LotID, 1)
+		if err != nil {
+			return err
+		}
+
+		update := models.LotUpdate{
+			Title:        lot.Title,
+			Description:  lot.Description,
+			StartPrice:   lot.StartPrice,
+			CurrentPrice: lot.CurrentPrice,
+			EndTime:      lot.EndTime,
+		}
+		if len(bids) > 0 {
+			if req.Title != nil || req.StartPrice != nil || req.EndTime != nil ||
+				len(*req.Description) <= len(lot.Description) ||
+				!strings.HasPrefix(*req.Description, lot.Description) {
+				return errs.ErrLotEditRestricted
+			}
+			update.Description = *req.Description
+		} else {
+			if req.Title != nil {
+				update.Title = *req.Title
+			}
+			if req.Description != nil {
+				update.Description = *req.Description
+			}
+			if req.StartPrice != nil {
+				update.StartPrice = *req.StartPrice
+				update.CurrentPrice = *req.StartPrice
+			}
+			if req.EndTime != nil {
+				update.EndTime = *req.EndTime
+			}
+		}
+		if update.Title == lot.Title && update.Description == lot.Description &&
+			update.StartPrice == lot.StartPrice && update.EndTime.Equal(lot.EndTime) {
+			return nil
+		}
+
+		err = s.validateLot(models.Lot{
+			Title:             update.Title,
+			Description:       update.Description,
+			StartPrice:        update.StartPrice,
+			StartTime:         lot.StartTime,
+			EndTime:           update.EndTime,
+			IncrementSchedule: lot.IncrementSchedule,
+			ReservePrice:      lot.ReservePrice,
+			BuyNowPrice:       lot.BuyNowPrice,
+			AuctionType:       lot.AuctionType,
+			FloorPrice:        lot.FloorPrice,
+			PriceDecrement:    lot.PriceDecrement,
+			DecrementSeconds:  lot.DecrementSeconds,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.lotRepo.CreateLotRevision(ctx, models.LotRevision{
+			LotID:       lot.ID,
+			EditorID:    sellerID,
+			Title:       lot.Title,
+			Description: lot.Description,
+			StartPrice:  lot.StartPrice,
+			EndTime:     lot.EndTime,
+		})
+		if err != nil {
+			return err
+		}
+		return s.lotRepo.UpdateLotDetails(ctx, lot.ID, update)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetLotByID(ctx, req.LotID)
+}
+
+func (s *LotService) GetLotRevisions(ctx context.Context, lotID int) ([]models.LotRevision, error) {
+	if lotID <= 0 {
+		return nil, errs.ErrInvalidLotID
+	}
+	if _, err := s.lotRepo.GetLotByID(ctx, lotID); err != nil {
+		return nil, err
+	}
+	return s.lotRepo.GetLotRevisions(ctx, lotID)
+}
+
+func (s *LotService) GetLotHistory(ctx context.Context, lotID int) ([]models.LotStatusChange, error) {
+	if lotID <= 0 {
+		return nil, errs.ErrInvalidLotID
 	}
-	return s.lotRepo.DeleteLot(ctx, lotID)
+	if _, err := s.lotRepo.GetLotByID(ctx, lotID); err != nil {
+		return nil, err
+	}
+	return s.lotRepo.GetStatusHistory(ctx, lotID)
+}
+
+// notifyBidders mails everyone who bid on lot after it was cancelled or, when
+// winner is set, sold early. Delivery failures are logged, not returned: the
+// status change has already been committed.
+func (s *LotService) notifyBidders(ctx context.Context, lot *models.LotResponse, winner *models.Winner,
+	reason string) {
+	bidderIDs, err := s.bidRepo.GetLotBidderIDs(ctx, lot.ID)
+	if err != nil {
+		log.Printf("error loading bidders of lot %d: %v", lot.ID, err)
+		return
+	}
+	for _, bidderID := range bidderIDs {
+		user, err := s.userRepo.GetUserByID(ctx, bidderID)
+		if err != nil {
+			log.Printf("error loading bidder %d of lot %d: %v", bidderID, lot.ID, err)
+			continue
+		}
+		msg := mail.Message{To: user.Email}
+		switch {
+		case winner == nil:
+			msg.Subject = fmt.Sprintf("Auction cancelled: %s", lot.Title)
+			msg.Body = fmt.Sprintf("Hello, %s!\n\nThe auction for \"%s\" was cancelled (%s). "+
+				"Your bids on it are void.", user.Username, lot.Title, reason)
+		case winner.UserID == user.ID:
+			msg.Subject = fmt.Sprintf("You won: %s", lot.Title)
+			msg.Body = fmt.Sprintf("Hello, %s!\n\nThe auction for \"%s\" was %s and you won it for %d.",
+				user.Username, lot.Title, reason, winner.Amount)
+		default:
+			msg.Subject = fmt.Sprintf("Auction ended: %s", lot.Title)
+			msg.Body = fmt.Sprintf("Hello, %s!\n\nThe auction for \"%s\" was %s. Another bidder won it.",
+				user.Username, lot.Title, reason)
+		}
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Printf("error notifying bidder %d of lot %d: %v", bidderID, lot.ID, err)
+		}
+	}
+}
+
+func checkEmailVerified(ctx context.Context, userRepo repository.UserRepository, userID int) error {
+	verified, err := userRepo.IsEmailVerified(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return errs.ErrEmailNotVerified
+	}
+	return nil
 }
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLotServiceNextMinimumBid(t *testing.T) {
+	ctx := context.Background()
+	f := newAuctionFixture(t, AntiSniping{})
+	lotID := f.addLot(t, models.LotCreate{StartPrice: 100})
+
+	lot, err := f.lotService.GetLotByID(ctx, lotID)
+	if err != nil {
+		t.Fatalf("get lot: %v", err)
+	}
+	if lot.NextMinimumBid != 110 {
+		t.Fatalf("before the first bid: want next minimum 110, got %d", lot.NextMinimumBid)
+	}
+	if _, err := f.bidService.CreateBid(ctx, bob, models.PlaceBid{LotID: lotID, Amount: 110}); err != nil {
+		t.Fatalf("bid: %v", err)
+	}
+	lot, _ = f.lotService.GetLotByID(ctx, lotID)
+	if lot.NextMinimumBid != 120 {
+		t.Fatalf("after the first bid: want next minimum 120, got %d", lot.NextMinimumBid)
+	}
+}
+
+func TestLotServiceBuyNow(t *testing.T) {
+	tests := []struct {
+		name       string
+		lot        models.LotCreate
+		buyer      int
+		wantErr    error
+		wantAmount int
+	}{
+		{name: "sells at the buy-now price", lot: models.LotCreate{StartPrice: 100, BuyNowPrice: 500},
+			buyer: bob, wantAmount: 500},
+		{name: "no buy-now price", lot: models.LotCreate{StartPrice: 100}, buyer: bob,
+			wantErr: errs.ErrBuyNowUnavailable},
+		{name: "bidding reached the buy-now price",
+			lot:   models.LotCreate{StartPrice: 100, CurrentPrice: 500, BuyNowPrice: 500},
+			buyer: bob, wantErr: errs.ErrBuyNowUnavailable},
+		{name: "seller cannot buy", lot: models.LotCreate{StartPrice: 100, BuyNowPrice: 500}, buyer: sellerID,
+			wantErr: errs.ErrCannotBidOnOwnLot},
+		{name: "closed lot", lot: models.LotCreate{StartPrice: 100, BuyNowPrice: 500,
+			Status: models.LotStatusClosed}, buyer: bob, wantErr: errs.ErrLotClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuctionFixture(t, AntiSniping{})
+			lotID := f.addLot(t, tt.lot)
+			winner, err := f.lotService.BuyNow(context.Background(), tt.buyer, lotID)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			checkSold(t, f, lotID, winner, tt.buyer, tt.wantAmount, models.LotReasonBuyNow)
+		})
+	}
+}
+
+func TestLotServiceAcceptPrice(t *testing.T) {
+	dutch := func(startedAgo time.Duration) models.LotCreate {
+		return models.LotCreate{StartPrice: 1000, AuctionType: models.AuctionTypeDutch, FloorPrice: 600,
+			PriceDecrement: 50, DecrementSeconds: 60, StartTime: time.Now().Add(-startedAgo)}
+	}
+	tests := []struct {
+		name       string
+		lot        models.LotCreate
+		wantErr    error
+		wantAmount int
+	}{
+		{name: "accepts the current price", lot: dutch(3*time.Minute + 30*time.Second), wantAmount: 850},
+		{name: "accepts the floor", lot: dutch(2 * time.Hour), wantAmount: 600},
+		{name: "not a dutch lot", lot: models.LotCreate{StartPrice: 100}, wantErr: errs.ErrNotDutchAuction},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newAuctionFixture(t, AntiSniping{})
+			lotID := f.addLot(t, tt.lot)
+			winner, err := f.lotService.AcceptPrice(context.Background(), bob, lotID)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			checkSold(t, f, lotID, winner, bob, tt.wantAmount, models.LotReasonPriceAccepted)
+		})
+	}
+}
+
+func checkSold(t *testing.T, f *auctionFixture, lotID int, winner *models.Winner, buyer, amount int,
+	reason string) {
+	t.Helper()
+	if winner.UserID != buyer || winner.Amount != amount {
+		t.Fatalf("want user %d to pay %d, got %+v", buyer, amount, winner)
+	}
+	lot := f.lot(t, lotID)
+	if lot.Status != models.LotStatusClosed || lot.CurrentPrice != amount {
+		t.Fatalf("want the lot closed at %d, got %s at %d", amount, lot.Status, lot.CurrentPrice)
+	}
+	if stored, err := f.winners.GetWinnerByLotID(context.Background(), lotID); err != nil || *stored != *winner {
+		t.Fatalf("winner not stored: %+v, %v", stored, err)
+	}
+	history, _ := f.lots.GetStatusHistory(context.Background(), lotID)
+	if len(history) != 1 || history[0].Reason != reason {
+		t.Fatalf("want one change with reason %q, got %+v", reason, history)
+	}
+}
+
+func TestLotServiceUpdateLot(t *testing.T) {
+	const description = "A working film camera from 1970."
+	tests := []struct {
+		name          string
+		withBid       bool
+		req           models.UpdateLotRequest
+		wantErr       error
+		wantTitle     string
+		wantDesc      string
+		wantRevisions int
+	}{
+		{name: "edit before bidding", req: models.UpdateLotRequest{Title: ptrTo("Old film camera")},
+			wantTitle: "Old film camera", wantDesc: description, wantRevisions: 1},
+		{name: "unchanged before bidding", req: models.UpdateLotRequest{Title: ptrTo("Vintage camera"),
+			Description: ptrTo(description)}, wantTitle: "Vintage camera", wantDesc: description},
+		{name: "extend the description after bidding", withBid: true,
+			req:       models.UpdateLotRequest{Description: ptrTo(description + " Lens included.")},
+			wantTitle: "Vintage camera", wantDesc: description + " Lens included.", wantRevisions: 1},
+		{name: "same description after bidding", withBid: true,
+			req:     models.UpdateLotRequest{Description: ptrTo(description)},
+			wantErr: errs.ErrLotEditRestricted, wantTitle: "Vintage camera", wantDesc: description},
+		{name: "rewrite the description after bidding", withBid: true,
+			req:     models.UpdateLotRequest{Description: ptrTo("A broken film camera from 1970, for parts.")},
+			wantErr: errs.ErrLotEditRestricted, wantTitle: "Vintage camera", wantDesc: description},
+		{name: "retitle after bidding", withBid: true,
+			req: models.UpdateLotRequest{Title: ptrTo("Old film camera"),
+				Description: ptrTo(description + " Lens included.")},
+			wantErr: errs.ErrLotEditRestricted, wantTitle: "Vintage camera", wantDesc: description},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newAuctionFixture(t, AntiSniping{})
+			lotID := f.addLot(t, models.LotCreate{StartPrice: 100, Description: description})
+			if tt.withBid {
+				if _, err := f.bidService.CreateBid(ctx, bob, models.PlaceBid{LotID: lotID, Amount: 110}); err != nil {
+					t.Fatalf("bid: %v", err)
+				}
+			}
+
+			tt.req.LotID = lotID
+			if _, err := f.lotService.UpdateLot(ctx, sellerID, tt.req); err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			lot := f.lot(t, lotID)
+			if lot.Title != tt.wantTitle || lot.Description != tt.wantDesc {
+				t.Fatalf("want %q / %q, got %q / %q", tt.wantTitle, tt.wantDesc, lot.Title, lot.Description)
+			}
+			if len(f.lots.revisions) != tt.wantRevisions {
+				t.Fatalf("want %d revisions, got %d", tt.wantRevisions, len(f.lots.revisions))
+			}
+		})
+	}
+}
+
+func ptrTo(s string) *string {
+	return &s
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"auction/internal/pkg"
+	"auction/internal/utils"
+	"context"
+	"time"
+)
+
+const (
+	mfaIssuer          = "Auction"
+	recoveryCodesCount = 10
+)
+
+// EnrollMFA stores a new TOTP secret awaiting confirmation by ConfirmMFA.
+func (s *AuthService) EnrollMFA(ctx context.Context, userID int) (*models.MFAEnrollResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SetPendingSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+	return &models.MFAEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(mfaIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmMFA enables two-factor authentication once the user proves the
+// authenticator works, and returns the initial recovery codes.
+func (s *AuthService) ConfirmMFA(ctx context.Context, userID int, code string) ([]string, error) {
+	state, err := s.mfaRepo.GetMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state.EnabledAt != nil {
+		return nil, errs.ErrMFAAlreadyEnabled
+	}
+	if state.Secret == "" {
+		return nil, errs.ErrMFANotEnabled
+	}
+	step, ok := utils.ValidateTOTP(state.Secret, code, time.Now())
+	if !ok {
+		return nil, errs.ErrMFACodeInvalid
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.mfaRepo.EnableMFA(ctx, userID, step); err != nil {
+			return err
+		}
+		return s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID int, code, ip string) ([]string, error) {
+	if err := s.verifyAccountSecondFactor(ctx, userID, code, ip); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *AuthService) DisableMFA(ctx context.Context, userID int, code, ip string) error {
+	if err := s.verifyAccountSecondFactor(ctx, userID, code, ip); err != nil {
+		return err
+	}
+	return s.mfaRepo.DisableMFA(ctx, userID)
+}
+
+// LoginMFA completes a login started by Login with a TOTP code or a recovery
+// code. The challenge token is single-use once the second factor is accepted.
+func (s *AuthService) LoginMFA(ctx context.Context, req models.MFALoginRequest, ip string) (*LoginResult, error) {
+	claims, err := pkg.ValidateToken(req.MFAToken, pkg.TokenTypeMFAChallenge)
+	if err != nil {
+		return nil, errs.ErrInvalidToken
+	}
+	revoked, err := s.revocations.IsTokenRevoked(ctx, claims.ID, claims.UserID, claims.IssuedAt.Time,
+		claims.TokenVersion)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errs.ErrInvalidToken
+	}
+
+	if result, err := s.reserveLoginAttempt(ctx, claims.Username, ip); err != nil {
+		return result, err
+	}
+	if err := s.verifySecondFactor(ctx, claims.UserID, req.Code, req.RecoveryCode); err != nil {
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeToken(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, claims.UserID)
+	if err == errs.ErrUserNotFound {
+		return nil, errs.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordLoginSuccess(ctx, claims.Username, ip)
+	tokens, err := s.issueTokens(ctx, *user, "")
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// verifyAccountSecondFactor checks a TOTP code for a signed-in user. It is
+// throttled by the login guard like a login, so a stolen access token cannot
+// be used to guess codes.
+func (s *AuthService) verifyAccountSecondFactor(ctx context.Context, userID int, code, ip string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.reserveLoginAttempt(ctx, user.Username, ip); err != nil {
+		return err
+	}
+	if err := s.verifySecondFactor(ctx, userID, code, ""); err != nil {
+		return err
+	}
+	s.recordLoginSuccess(ctx, user.Username, ip)
+	return nil
+}
+
+// verifySecondFactor checks a TOTP code, or a recovery code when one is given.
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID int, code, recoveryCode string) error {
+	state, err := s.mfaRepo.GetMFA(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if state.EnabledAt == nil {
+		return errs.ErrMFANotEnabled
+	}
+	if recoveryCode != "" {
+		return s.mfaRepo.UseRecoveryCode(ctx, userID, utils.HashRecoveryCode(recoveryCode))
+	}
+	step, ok := utils.ValidateTOTP(state.Secret, code, time.Now())
+	if !ok {
+		return errs.ErrMFACodeInvalid
+	}
+	return s.mfaRepo.UseTOTPStep(ctx, userID, step)
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"auction/internal/oidc"
+	"auction/internal/repository"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	oidcStateTTL = 10 * time.Minute
+
+	// unusablePasswordHash never matches a password, so accounts created
+	// through an identity provider cannot log in with one until it is reset.
+	unusablePasswordHash = "!"
+
+	maxUsernameLength = 32
+	usernameAttempts  = 5
+)
+
+type OIDCService struct {
+	providers   map[string]oidc.Provider
+	oidcRepo    repository.OIDCRepository
+	userRepo    repository.UserRepository
+	mfaRepo     repository.MFARepository
+	tx          repository.Transactor
+	authService *AuthService
+}
+
+func NewOIDCService(providers []oidc.Provider, oidcRepo repository.OIDCRepository,
+	userRepo repository.UserRepository, mfaRepo repository.MFARepository, tx repository.Transactor,
+	authService *AuthService) *OIDCService {
+	byName := make(map[string]oidc.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &OIDCService{
+		providers:   byName,
+		oidcRepo:    oidcRepo,
+		userRepo:    userRepo,
+		mfaRepo:     mfaRepo,
+		tx:          tx,
+		authService: authService,
+	}
+}
+
+// Start records a new login state and returns the provider URL the user is
+// redirected to, together with a binding the browser must present on the
+// callback. The binding ties the state to the browser that started the
+// login, so a callback link made from someone else's login is refused.
+func (s *OIDCService) Start(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return "", "", errs.ErrUnknownProvider
+	}
+	state := models.OIDCLoginState{
+		State:        oidc.NewRandomString(),
+		Provider:     providerName,
+		Nonce:        oidc.NewRandomString(),
+		CodeVerifier: oidc.NewRandomString(),
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	}
+	if err := s.oidcRepo.CreateLoginState(ctx, state); err != nil {
+		return "", "", err
+	}
+	authURL := provider.AuthCodeURL(state.State, state.Nonce, oidc.CodeChallengeS256(state.CodeVerifier))
+	return authURL, stateBinding(state.State), nil
+}
+
+// Callback redeems the authorization code and logs in the user linked to the
+// external identity, linking or creating one on first sign-in. binding is the
+// value Start returned for state.
+func (s *OIDCService) Callback(ctx context.Context, state, binding, code string) (*LoginResult, error) {
+	if subtle.ConstantTimeCompare([]byte(binding), []byte(stateBinding(state))) != 1 {
+		return nil, errs.ErrOIDCStateInvalid
+	}
+	loginState, err := s.oidcRepo.UseLoginState(ctx, state, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := s.providers[loginState.Provider]
+	if !ok {
+		return nil, errs.ErrUnknownProvider
+	}
+	identity, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		log.Printf("oidc exchange with %s failed: %v", loginState.Provider, err)
+		return nil, errs.ErrInvalidCredentials
+	}
+
+	user, err := s.userForIdentity(ctx, loginState.Provider, identity)
+	if err != nil {
+		return nil, err
+	}
+	// The provider does not know about our second factor, so it is still
+	// required for accounts that enabled it.
+	if user.MFAEnabled {
+		mfaToken, err := s.authService.issueMFAChallenge(ctx, *user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: mfaToken}, nil
+	}
+	tokens, err := s.authService.issueTokens(ctx, *user, "")
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: tokens}, nil
+}
+
+// userForIdentity finds the user linked to identity. An unlinked identity is
+// linked to the user with the same email only when the provider has verified
+// that email; otherwise a new user is created. An existing account that never
+// verified the email is reset before linking: whoever registered it had not
+// proved they own the address, and must not keep access to the owner's
+// account through a password, sessions or MFA they set up.
+func (s *OIDCService) userForIdentity(ctx context.Context, providerName string,
+	identity *oidc.Identity) (*models.User, error) {
+	userID, err := s.oidcRepo.GetUserIDByIdentity(ctx, providerName, identity.Subject)
+	if err == nil {
+		return s.userRepo.GetUserByID(ctx, userID)
+	}
+	if err != errs.ErrIdentityNotFound {
+		return nil, err
+	}
+	if identity.Email == "" {
+		return nil, errs.ErrOIDCEmailMissing
+	}
+
+	var user *models.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		existing, err := s.userRepo.GetUserByEmail(ctx, identity.Email)
+		switch {
+		case err == nil && !identity.EmailVerified:
+			return errs.ErrEmailAlreadyExists
+		case err == nil:
+			user = existing
+			if !user.EmailVerified {
+				if err := s.claimUnverifiedUser(ctx, user); err != nil {
+					return err
+				}
+			}
+		case err == errs.ErrUserNotFound:
+			user, err = s.createUser(ctx, identity)
+			if err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		return s.oidcRepo.CreateIdentity(ctx, models.UserIdentity{
+			Provider: providerName,
+			Subject:  identity.Subject,
+			UserID:   user.ID,
+			Email:    identity.Email,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// claimUnverifiedUser hands an account registered with an unverified email
+// over to the identity that proved ownership of that email.
+func (s *OIDCService) claimUnverifiedUser(ctx context.Context, user *models.User) error {
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, unusablePasswordHash); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.DisableMFA(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.authService.RevokeAllSessions(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.userRepo.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+		return err
+	}
+	user.Password = unusablePasswordHash
+	user.MFAEnabled = false
+	user.EmailVerified = true
+	return nil
+}
+
+func (s *OIDCService) createUser(ctx context.Context, identity *oidc.Identity) (*models.User, error) {
+	username, err := s.availableUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	user := models.User{
+		Username: username,
+		Email:    identity.Email,
+		Password: unusablePasswordHash,
+		Role:     models.RoleUser,
+	}
+	user.ID, err = s.userRepo.CreateUser(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if identity.EmailVerified {
+		if err := s.userRepo.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+			return nil, err
+		}
+		user.EmailVerified = true
+	}
+	return &user, nil
+}
+
+// availableUsername derives a username from the identity, adding a random
+// suffix when the preferred one is taken.
+func (s *OIDCService) availableUsername(ctx context.Context, identity *oidc.Identity) (string, error) {
+	base := identity.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = sanitizeUsername(base)
+
+	candidate := base
+	for i := 0; i < usernameAttempts; i++ {
+		if len(candidate) >= 3 {
+			_, err := s.userRepo.GetUserByUsername(ctx, candidate)
+			if err == errs.ErrUserNotFound {
+				return candidate, nil
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			return "", err
+		}
+		candidate = base + "_" + hex.EncodeToString(suffix)
+	}
+	return "", errs.ErrUsernameAlreadyExists
+}
+
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' || r == '.' {
+			b.WriteRune(r)
+		}
+	}
+	username := b.String()
+	if len(username) > maxUsernameLength-7 {
+		username = username[:maxUsernameLength-7]
+	}
+	if username == "" {
+		username = "user"
+	}
+	return username
+}
+
+// stateBinding is kept by the browser for the duration of a login. Only a hash
+// of the state is stored so the cookie is no use on its own.
+func stateBinding(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"auction/internal/oidc"
+	"context"
+	"net/url"
+	"testing"
+)
+
+type oidcFixture struct {
+	*authFixture
+	service  *OIDCService
+	oidcRepo *fakeOIDCRepo
+	provider *fakeProvider
+	// bindings holds what the browser keeps for each started login.
+	bindings map[string]string
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	auth := newAuthFixture(t)
+	f := &oidcFixture{
+		authFixture: auth,
+		oidcRepo:    newFakeOIDCRepo(),
+		bindings:    map[string]string{},
+		provider: &fakeProvider{identity: &oidc.Identity{
+			Subject:           "subject-1",
+			Email:             "alice@example.com",
+			EmailVerified:     true,
+			PreferredUsername: "Alice",
+		}},
+	}
+	f.service = NewOIDCService([]oidc.Provider{f.provider}, f.oidcRepo, auth.users, auth.mfa, fakeTransactor{},
+		auth.service)
+	return f
+}
+
+// start begins a login and returns the state the provider redirects back with.
+func (f *oidcFixture) start(t *testing.T) string {
+	t.Helper()
+	authURL, binding, err := f.service.Start(context.Background(), "mock")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parsing auth url: %v", err)
+	}
+	state := parsed.Query().Get("state")
+	f.bindings[state] = binding
+	return state
+}
+
+func TestOIDCServiceCallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, f *oidcFixture)
+		code    string
+		state   func(t *testing.T, f *oidcFixture) string
+		binding func(t *testing.T, f *oidcFixture) string
+		wantErr error
+		check   func(t *testing.T, f *oidcFixture, result *LoginResult)
+	}{
+		{
+			name: "linked identity",
+			setup: func(t *testing.T, f *oidcFixture) {
+				id := f.addUser(t, models.User{Username: "bob", Email: "bob@example.com", EmailVerified: true},
+					testPassword)
+				f.oidcRepo.identities["mock|subject-1"] = models.UserIdentity{Provider: "mock",
+					Subject: "subject-1", UserID: id}
+			},
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				wantTokens(t, result)
+				if len(f.users.users) != 1 {
+					t.Fatalf("a linked identity must not create users")
+				}
+			},
+		},
+		{
+			name: "link verified user",
+			setup: func(t *testing.T, f *oidcFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com", EmailVerified: true},
+					testPassword)
+			},
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				wantTokens(t, result)
+				wantLinked(t, f, 1)
+				user, _ := f.users.GetUserByID(context.Background(), 1)
+				if user.Password == unusablePasswordHash {
+					t.Fatalf("linking a verified account must keep its password")
+				}
+			},
+		},
+		{
+			name: "claim unverified user",
+			setup: func(t *testing.T, f *oidcFixture) {
+				ctx := context.Background()
+				id := f.addUser(t, models.User{Username: "alice", Email: "alice@example.com", MFAEnabled: true},
+					testPassword)
+				f.mfa.EnableMFA(ctx, id, 1)
+				if _, err := f.authFixture.service.issueTokens(ctx, models.User{ID: id, Username: "alice"},
+					""); err != nil {
+					t.Fatalf("issuing tokens: %v", err)
+				}
+			},
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				wantTokens(t, result)
+				wantLinked(t, f, 1)
+				user, _ := f.users.GetUserByID(context.Background(), 1)
+				if user.Password != unusablePasswordHash || !user.EmailVerified {
+					t.Fatalf("claimed account keeps its password or stays unverified: %+v", user)
+				}
+				if _, ok := f.mfa.states[1]; ok {
+					t.Fatalf("claimed account keeps its second factor")
+				}
+				if _, ok := f.revocations.revokedBefore[1]; !ok {
+					t.Fatalf("claimed account keeps its sessions")
+				}
+				active := 0
+				for _, token := range f.refresh.tokens {
+					if token.RevokedAt == nil {
+						active++
+					}
+				}
+				if active != 1 {
+					t.Fatalf("want only the new refresh token active, got %d", active)
+				}
+				if len(f.apiKeys.revokedFor) != 1 {
+					t.Fatalf("claimed account keeps its API keys")
+				}
+			},
+		},
+		{
+			name: "unverified provider email matching an account",
+			setup: func(t *testing.T, f *oidcFixture) {
+				f.provider.identity.EmailVerified = false
+				f.addUser(t, models.User{Username: "alice", Email: "alice@example.com", EmailVerified: true},
+					testPassword)
+			},
+			wantErr: errs.ErrEmailAlreadyExists,
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				wantLinked(t, f, 0)
+			},
+		},
+		{
+			name: "create user",
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				wantTokens(t, result)
+				user, err := f.users.GetUserByEmail(context.Background(), "alice@example.com")
+				if err != nil {
+					t.Fatalf("user was not created: %v", err)
+				}
+				if user.Username != "alice" || user.Role != models.RoleUser ||
+					user.Password != unusablePasswordHash || !user.EmailVerified {
+					t.Fatalf("unexpected new user %+v", user)
+				}
+				wantLinked(t, f, user.ID)
+			},
+		},
+		{
+			name: "create user with taken username",
+			setup: func(t *testing.T, f *oidcFixture) {
+				f.addUser(t, models.User{Username: "alice", Email: "other@example.com"}, testPassword)
+			},
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				user, err := f.users.GetUserByEmail(context.Background(), "alice@example.com")
+				if err != nil {
+					t.Fatalf("user was not created: %v", err)
+				}
+				if user.Username == "alice" || len(user.Username) != len("alice_")+6 {
+					t.Fatalf("want a suffixed username, got %q", user.Username)
+				}
+			},
+		},
+		{
+			name: "mfa challenge",
+			setup: func(t *testing.T, f *oidcFixture) {
+				id := f.addUser(t, models.User{Username: "alice", Email: "alice@example.com", EmailVerified: true,
+					MFAEnabled: true}, testPassword)
+				f.oidcRepo.identities["mock|subject-1"] = models.UserIdentity{Provider: "mock",
+					Subject: "subject-1", UserID: id}
+			},
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				if result.MFAToken == "" || result.Tokens != nil {
+					t.Fatalf("want only an MFA token, got %+v", result)
+				}
+			},
+		},
+		{
+			name:    "failed exchange",
+			code:    "bad-code",
+			wantErr: errs.ErrInvalidCredentials,
+		},
+		{
+			name:    "unknown state",
+			state:   func(t *testing.T, f *oidcFixture) string { return "unknown" },
+			binding: func(t *testing.T, f *oidcFixture) string { return stateBinding("unknown") },
+			wantErr: errs.ErrOIDCStateInvalid,
+		},
+		{
+			name: "reused state",
+			state: func(t *testing.T, f *oidcFixture) string {
+				state := f.start(t)
+				if _, err := f.service.Callback(context.Background(), state, f.bindings[state],
+					"good-code"); err != nil {
+					t.Fatalf("first callback: %v", err)
+				}
+				return state
+			},
+			wantErr: errs.ErrOIDCStateInvalid,
+		},
+		{
+			name:    "callback without the state cookie",
+			binding: func(t *testing.T, f *oidcFixture) string { return "" },
+			wantErr: errs.ErrOIDCStateInvalid,
+			check: func(t *testing.T, f *oidcFixture, result *LoginResult) {
+				wantLinked(t, f, 0)
+				if len(f.oidcRepo.states) != 1 {
+					t.Fatalf("a refused callback must leave the login state usable")
+				}
+			},
+		},
+		{
+			name: "callback with the cookie of another login",
+			binding: func(t *testing.T, f *oidcFixture) string {
+				return f.bindings[f.start(t)]
+			},
+			wantErr: errs.ErrOIDCStateInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newOIDCFixture(t)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			var state string
+			if tt.state != nil {
+				state = tt.state(t, f)
+			} else {
+				state = f.start(t)
+			}
+			binding := f.bindings[state]
+			if tt.binding != nil {
+				binding = tt.binding(t, f)
+			}
+			code := tt.code
+			if code == "" {
+				code = "good-code"
+			}
+
+			result, err := f.service.Callback(context.Background(), state, binding, code)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if tt.check != nil {
+				tt.check(t, f, result)
+			}
+		})
+	}
+}
+
+func TestOIDCServiceStartUnknownProvider(t *testing.T) {
+	f := newOIDCFixture(t)
+	if _, _, err := f.service.Start(context.Background(), "other"); err != errs.ErrUnknownProvider {
+		t.Fatalf("want ErrUnknownProvider, got %v", err)
+	}
+}
+
+func wantTokens(t *testing.T, result *LoginResult) {
+	t.Helper()
+	if result == nil || result.Tokens == nil || result.MFAToken != "" {
+		t.Fatalf("want a token pair, got %+v", result)
+	}
+}
+
+func wantLinked(t *testing.T, f *oidcFixture, userID int) {
+	t.Helper()
+	identity, ok := f.oidcRepo.identities["mock|subject-1"]
+	switch {
+	case userID == 0 && ok:
+		t.Fatalf("identity must not be linked, got user %d", identity.UserID)
+	case userID != 0 && (!ok || identity.UserID != userID):
+		t.Fatalf("identity is not linked to user %d: %+v", userID, identity)
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"auction/internal/errs"
+	"auction/internal/models"
+	"auction/internal/repository"
+	"context"
+	"fmt"
+)
+
+type RoleService struct {
+	roleRepo    repository.RoleRepository
+	userRepo    repository.UserRepository
+	audit       repository.AuditRepository
+	tx          repository.Transactor
+	authService *AuthService
+}
+
+func NewRoleService(roleRepo repository.RoleRepository, userRepo repository.UserRepository,
+	audit repository.AuditRepository, tx repository.Transactor, authService *AuthService) *RoleService {
+	return &RoleService{
+		roleRepo:    roleRepo,
+		userRepo:    userRepo,
+		audit:       audit,
+		tx:          tx,
+		authService: authService,
+	}
+}
+
+func (s *RoleService) HasPermission(ctx context.Context, userID int, permission string) (bool, error) {
+	return s.roleRepo.HasPermission(ctx, userID, permission)
+}
+
+func (s *RoleService) GetRoles(ctx context.Context) ([]models.Role, error) {
+	return s.roleRepo.GetRoles(ctx)
+}
+
+// AssignRole changes a user's role. Admins cannot change their own role, so
+// the last admin cannot lock everyone out by accident.
+func (s *RoleService) AssignRole(ctx context.Context, actorID, userID int, role string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.assignRole(ctx, actorID, userID, role, models.AuditEventRoleAssigned,
+			fmt.Sprintf("role %q assigned by user %d", role, actorID))
+	})
+}
+
+// BanUser moves the user to the banned role and ends all of their sessions.
+func (s *RoleService) BanUser(ctx context.Context, actorID, userID int, reason string) error {
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.assignRole(ctx, actorID, userID, models.RoleBanned, models.AuditEventUserBanned,
+			fmt.Sprintf("banned by user %d: %s", actorID, reason))
+	})
+	if err != nil {
+		return err
+	}
+	return s.authService.RevokeAllSessions(ctx, userID)
+}
+
+func (s *RoleService) assignRole(ctx context.Context, actorID, userID int, role, event, details string) error {
+	if actorID == userID {
+		return errs.ErrCannotChangeOwnRole
+	}
+	exists, err := s.roleRepo.RoleExists(ctx, role)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errs.ErrRoleNotFound
+	}
+	if err := s.userRepo.UpdateUserRole(ctx, userID, role); err != nil {
+		return err
+	}
+	return s.audit.CreateAuditEntry(ctx, models.AuditEntry{
+		Event:   event,
+		UserID:  &userID,
+		Details: details,
+	})
+}
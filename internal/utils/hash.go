@@ -1,24 +1,101 @@
 package utils
 
 import (
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"strings"
 )
 
-func HashPassword(password string) (string, error) {
-	hash := sha256.New()
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024
+	argonThreads = 2
+	argonKeyLen  = 32
+	argonSaltLen = 16
+)
+
+var errInvalidHash = errors.New("invalid password hash")
 
-	if _, err := hash.Write([]byte(password)); err != nil {
+// HashPassword returns an argon2id hash in PHC format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	hashBytes := hash.Sum(nil)
-	return hex.EncodeToString(hashBytes), nil
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
 }
 
 func CheckPasswordHash(password, hash string) bool {
-	hashedPassword, err := HashPassword(password)
+	if isLegacyHash(hash) {
+		sum := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(hash)) == 1
+	}
+	params, salt, key, err := decodeHash(hash)
+	if err != nil {
+		return false
+	}
+	other := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash reports whether hash is a legacy SHA-256 hash or was created
+// with weaker argon2id parameters than the current ones.
+func NeedsRehash(hash string) bool {
+	if isLegacyHash(hash) {
+		return true
+	}
+	params, _, _, err := decodeHash(hash)
+	if err != nil {
+		return true
+	}
+	return params.time < argonTime || params.memory < argonMemory || params.threads < argonThreads
+}
+
+type argonParams struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func decodeHash(hash string) (argonParams, []byte, []byte, error) {
+	var params argonParams
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errInvalidHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, errInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
 	if err != nil {
+		return params, nil, nil, errInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, errInvalidHash
+	}
+	return params, salt, key, nil
+}
+
+func isLegacyHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
 		return false
 	}
-	return hashedPassword == hash
+	_, err := hex.DecodeString(hash)
+	return err == nil
 }
@@ -3,11 +3,15 @@ package main
 import (
 	_ "auction/docs"
 	"auction/internal/handlers"
+	"auction/internal/mail"
 	"auction/internal/middleware"
+	"auction/internal/models"
+	"auction/internal/oidc"
+	"auction/internal/pkg"
 	"auction/internal/repository"
 	"auction/internal/service"
+	"context"
 	"database/sql"
-	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -15,6 +19,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 )
 
 // @title AuctionInfo
@@ -26,47 +31,211 @@ import (
 // @name Authorization
 func main() {
 	godotenv.Load(".env")
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET environment variable not set")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signingAlg := os.Getenv("JWT_SIGNING_ALG")
+	if signingAlg == "" {
+		signingAlg = "RS256"
+	}
+	keysDir := os.Getenv("JWT_KEYS_DIR")
+	if keysDir == "" {
+		log.Println("WARNING: JWT_KEYS_DIR is not set, signing keys are kept in memory only. " +
+			"Every restart invalidates all issued tokens and replicas reject each other's tokens. " +
+			"Set JWT_KEYS_DIR to a directory shared by all instances in production.")
+	}
+	keyManager, err := pkg.NewKeyManager(signingAlg, keysDir, pkg.RefreshTokenTTL)
+	if err != nil {
+		log.Fatalf("error loading signing keys: %v", err)
+	}
+	pkg.SetKeyManager(keyManager)
+	if iss, aud := os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE"); iss != "" && aud != "" {
+		pkg.SetIssuer(iss, aud)
 	}
+	go keyManager.Run(ctx, durationFromEnv("JWT_KEY_ROTATION", 24*time.Hour))
+
 	post := "user=postgres password=Ambb5xh5dr6ss dbname=auction host=localhost port=5432 sslmode=disable"
 	db, err := sql.Open("postgres", post)
 	if err != nil {
-		fmt.Printf("error open db", err)
+		log.Fatalf("error open db: %v", err)
 	}
 	defer db.Close()
 
 	lotRepo := repository.NewPostgresLotRepository(db)
 	bidRepo := repository.NewPostgresBidRepository(db)
+	proxyBidRepo := repository.NewPostgresProxyBidRepository(db)
 	userRepo := repository.NewPostgresUserRepository(db)
+	winnerRepo := repository.NewPostgresWinnerRepository(db)
+	refreshTokenRepo := repository.NewPostgresRefreshTokenRepository(db)
+	revocationRepo := repository.NewPostgresTokenRevocationRepository(db)
+	passwordResetRepo := repository.NewPostgresPasswordResetRepository(db)
+	mfaRepo := repository.NewPostgresMFARepository(db)
+	loginAttemptRepo := repository.NewPostgresLoginAttemptRepository(db)
+	auditRepo := repository.NewPostgresAuditRepository(db)
+	roleRepo := repository.NewPostgresRoleRepository(db)
+	apiKeyRepo := repository.NewPostgresAPIKeyRepository(db)
+	oidcRepo := repository.NewPostgresOIDCRepository(db)
+	transactor := repository.NewPostgresTransactor(db)
+
+	increments := service.DefaultIncrementSchedule
+	if v := os.Getenv("BID_INCREMENT_SCHEDULE"); v != "" {
+		increments, err = service.ParseIncrementSchedule(v)
+		if err != nil {
+			log.Fatalf("invalid BID_INCREMENT_SCHEDULE: %v", err)
+		}
+	}
 
-	lotService := service.NewLotService(lotRepo, userRepo)
-	bidService := service.NewBidService(bidRepo, lotRepo)
+	var mailer mail.Sender = mail.NewLogSender()
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		mailer, err = mail.NewFileSender(dir)
+		if err != nil {
+			log.Fatalf("error creating mail sender: %v", err)
+		}
+	}
 
-	authHandler := handlers.NewAuthHandler(db)
+	lotService := service.NewLotService(lotRepo, userRepo, winnerRepo, bidRepo, transactor, increments, mailer)
+	bidService := service.NewBidService(bidRepo, lotRepo, proxyBidRepo, userRepo, transactor, increments, service.AntiSniping{
+		Window:    durationFromEnv("ANTI_SNIPING_WINDOW", 2*time.Minute),
+		Extension: durationFromEnv("ANTI_SNIPING_EXTENSION", 2*time.Minute),
+	})
+
+	closer := service.NewAuctionCloser(lotRepo, bidRepo, winnerRepo, transactor,
+		durationFromEnv("AUCTION_CLOSE_INTERVAL", 30*time.Second))
+	go closer.Run(ctx)
+
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8081"
+	}
+
+	// The password reset email links to PASSWORD_RESET_URL, the frontend page
+	// that asks for the new password. Without a frontend the API serves a
+	// minimal page of its own.
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = baseURL + "/reset-password"
+	}
+
+	userLoginPolicy := service.DefaultUserLoginPolicy
+	userLoginPolicy.LockoutDuration = durationFromEnv("LOGIN_LOCKOUT_DURATION", userLoginPolicy.LockoutDuration)
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, auditRepo, transactor, userLoginPolicy,
+		service.DefaultIPLoginPolicy)
+	authService := service.NewAuthService(userRepo, refreshTokenRepo, revocationRepo, passwordResetRepo, mfaRepo,
+		apiKeyRepo, transactor, loginGuard, mailer, baseURL, resetURL)
+
+	jwksHandler := handlers.NewJWKSHandler(keyManager)
+	roleService := service.NewRoleService(roleRepo, userRepo, auditRepo, transactor, authService)
+	authHandler := handlers.NewAuthHandler(authService)
+	roleHandler := handlers.NewRoleHandler(roleService)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, roleRepo, userRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	oidcService := service.NewOIDCService(oidcProviders(ctx, baseURL), oidcRepo, userRepo, mfaRepo,
+		transactor, authService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
 	lotHandler := handlers.NewLotHandler(db, lotService)
 	bidHandler := handlers.NewBidHandler(db, bidService)
 
 	r := mux.NewRouter()
 
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	r.HandleFunc("/.well-known/jwks.json", jwksHandler.GetJWKS)
 
 	r.HandleFunc("/api/register", authHandler.Register)
 	r.HandleFunc("/api/login", authHandler.Login)
+	r.HandleFunc("/api/login/mfa", authHandler.LoginMFA)
+	r.HandleFunc("/api/refresh", authHandler.RefreshToken)
+	r.HandleFunc("/api/verify-email", authHandler.VerifyEmail)
+	r.HandleFunc("/api/password/forgot", authHandler.ForgotPassword)
+	r.HandleFunc("/api/password/reset", authHandler.ResetPassword)
+	r.HandleFunc("/reset-password", authHandler.ResetPasswordPage)
+	r.HandleFunc("/api/oidc/login", oidcHandler.Login)
+	r.HandleFunc("/api/oidc/callback", oidcHandler.Callback)
 	r.HandleFunc("/api/lots", lotHandler.GetLots)
 	r.HandleFunc("/api/lot", lotHandler.GetLotByID)
+	r.HandleFunc("/api/lot/history", lotHandler.GetLotHistory)
+	r.HandleFunc("/api/lot/revisions", lotHandler.GetLotRevisions)
 
 	auth := r.PathPrefix("/auth").Subrouter()
-	auth.Use(middleware.AuthMiddleware)
+	auth.Use(middleware.NewAuth(revocationRepo, apiKeyService).AuthMiddleware)
 
-	auth.HandleFunc("/lots/create", lotHandler.CreateLot)
-	auth.HandleFunc("/bids/create", bidHandler.CreateBid)
-	auth.HandleFunc("/bids/my", bidHandler.GetMyBids)
+	// can wraps a handler so it runs only for users whose role grants permission.
+	can := func(permission string, h http.HandlerFunc) http.Handler {
+		return middleware.RequirePermission(roleService, permission)(h)
+	}
 
-	auth.HandleFunc("/lot/delete", lotHandler.DeleteLot)
+	auth.Handle("/lots/create", can(models.PermLotCreate, lotHandler.CreateLot))
+	auth.Handle("/lots/buy-now", can(models.PermLotBuy, lotHandler.BuyNow))
+	auth.Handle("/lots/accept", can(models.PermLotBuy, lotHandler.AcceptPrice))
+	auth.Handle("/lots/cancel", can(models.PermLotManage, lotHandler.CancelLot))
+	auth.Handle("/lots/end", can(models.PermLotManage, lotHandler.EndLot))
+	auth.Handle("/lots/update", can(models.PermLotManage, lotHandler.UpdateLot)).Methods(http.MethodPatch)
+	auth.Handle("/bids/create", can(models.PermBidPlace, bidHandler.CreateBid))
+	auth.Handle("/bids/my", can(models.PermBidReadOwn, bidHandler.GetMyBids))
+
+	auth.Handle("/lot/delete", can(models.PermLotDelete, lotHandler.DeleteLot))
+
+	account := auth.NewRoute().Subrouter()
+	account.Use(middleware.RequireToken)
+	account.HandleFunc("/logout", authHandler.Logout)
+	account.HandleFunc("/verify-email/resend", authHandler.ResendVerification)
+	account.HandleFunc("/mfa/enroll", authHandler.EnrollMFA)
+	account.HandleFunc("/mfa/confirm", authHandler.ConfirmMFA)
+	account.HandleFunc("/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+	account.HandleFunc("/mfa/disable", authHandler.DisableMFA)
+	account.HandleFunc("/api-keys", apiKeyHandler.GetAPIKeys)
+	account.HandleFunc("/api-keys/create", apiKeyHandler.CreateAPIKey)
+	account.HandleFunc("/api-keys/revoke", apiKeyHandler.RevokeAPIKey)
+
+	admin := auth.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireToken)
+	admin.Handle("/users/revoke-sessions", can(models.PermSessionRevoke, authHandler.RevokeUserSessions))
+	admin.Handle("/users/ban", can(models.PermUserBan, roleHandler.BanUser))
+	admin.Handle("/users/role", can(models.PermRoleAssign, roleHandler.AssignRole))
+	admin.Handle("/roles", can(models.PermRoleAssign, roleHandler.GetRoles))
 
 	log.Println("The server is running at :8081")
 	log.Fatal(http.ListenAndServe(":8081", r))
 
 }
+
+// oidcProviders configures the identity provider from OIDC_* variables. Any
+// issuer serving a discovery document works, including a local mock provider.
+func oidcProviders(ctx context.Context, baseURL string) []oidc.Provider {
+	issuer := os.Getenv("OIDC_ISSUER_URL")
+	if issuer == "" {
+		return nil
+	}
+	name := os.Getenv("OIDC_PROVIDER_NAME")
+	if name == "" {
+		name = "corp"
+	}
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = baseURL + "/api/oidc/callback"
+	}
+
+	discoverCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	provider, err := oidc.Discover(discoverCtx, name, oidc.Config{
+		IssuerURL:    issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  redirectURL,
+	}, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		log.Fatalf("error configuring identity provider %s: %v", name, err)
+	}
+	return []oidc.Provider{provider}
+}
+
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return d
+}
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS winners_lot_id_idx;
+
+ALTER TABLE winners
+    DROP COLUMN IF EXISTS amount;
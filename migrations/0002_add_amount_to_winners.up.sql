@@ -0,0 +1,4 @@
+ALTER TABLE winners
+    ADD COLUMN IF NOT EXISTS amount INT NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX IF NOT EXISTS winners_lot_id_idx ON winners (lot_id);
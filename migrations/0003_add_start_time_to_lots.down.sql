@@ -0,0 +1,2 @@
+ALTER TABLE lots
+    DROP COLUMN IF EXISTS start_time;
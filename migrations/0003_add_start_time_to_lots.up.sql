@@ -0,0 +1,8 @@
+ALTER TABLE lots
+    ADD COLUMN IF NOT EXISTS start_time TIMESTAMP;
+
+UPDATE lots SET start_time = created_at WHERE start_time IS NULL;
+
+ALTER TABLE lots
+    ALTER COLUMN start_time SET NOT NULL,
+    ALTER COLUMN start_time SET DEFAULT CURRENT_TIMESTAMP;
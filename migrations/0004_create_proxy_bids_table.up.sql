@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS proxy_bids (
+    lot_id INT NOT NULL,
+    user_id INT NOT NULL,
+    max_amount INT NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (lot_id, user_id)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE lots
+    DROP COLUMN IF EXISTS increment_schedule;
@@ -0,0 +1,2 @@
+ALTER TABLE lots
+    ADD COLUMN IF NOT EXISTS increment_schedule JSONB;
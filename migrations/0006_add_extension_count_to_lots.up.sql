@@ -0,0 +1,2 @@
+ALTER TABLE lots
+    ADD COLUMN IF NOT EXISTS extension_count INT NOT NULL DEFAULT 0;
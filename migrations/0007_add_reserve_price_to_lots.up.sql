@@ -0,0 +1,2 @@
+ALTER TABLE lots
+    ADD COLUMN IF NOT EXISTS reserve_price INT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE lots
+    ADD COLUMN IF NOT EXISTS auction_type VARCHAR(32) NOT NULL DEFAULT 'english';
@@ -0,0 +1,4 @@
+ALTER TABLE lots
+    DROP COLUMN IF EXISTS decrement_seconds,
+    DROP COLUMN IF EXISTS price_decrement,
+    DROP COLUMN IF EXISTS floor_price;
@@ -0,0 +1,4 @@
+ALTER TABLE lots
+    ADD COLUMN IF NOT EXISTS floor_price INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS price_decrement INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS decrement_seconds INT NOT NULL DEFAULT 0;
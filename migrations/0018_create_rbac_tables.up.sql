@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(32) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Buyer and seller'),
+    ('admin', 'Moderator'),
+    ('banned', 'Suspended account without permissions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('lot:create', 'Create lots'),
+    ('lot:buy', 'Buy lots at the buy-now or dutch price'),
+    ('lot:delete', 'Delete any lot'),
+    ('bid:place', 'Place bids'),
+    ('bid:read_own', 'List own bids'),
+    ('session:revoke', 'Revoke sessions of any user'),
+    ('user:ban', 'Ban users'),
+    ('role:assign', 'View roles and assign them to users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'lot:create'),
+    ('user', 'lot:buy'),
+    ('user', 'bid:place'),
+    ('user', 'bid:read_own'),
+    ('admin', 'lot:delete'),
+    ('admin', 'session:revoke'),
+    ('admin', 'user:ban'),
+    ('admin', 'role:assign')
+ON CONFLICT DO NOTHING;
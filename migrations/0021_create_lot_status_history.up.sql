@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS lot_status_history (
+    id SERIAL PRIMARY KEY,
+    lot_id INT NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    actor_id INT,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS lot_status_history_lot_id_idx ON lot_status_history (lot_id, created_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS lot_revisions (
+    id SERIAL PRIMARY KEY,
+    lot_id INT NOT NULL,
+    editor_id INT NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    start_price INT NOT NULL,
+    end_time TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS lot_revisions_lot_id_idx ON lot_revisions (lot_id, created_at);
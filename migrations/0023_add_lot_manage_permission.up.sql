@@ -0,0 +1,7 @@
+INSERT INTO permissions (name, description) VALUES
+    ('lot:manage', 'Edit, cancel and end own lots')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'lot:manage')
+ON CONFLICT DO NOTHING;
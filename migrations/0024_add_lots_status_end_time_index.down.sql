@@ -0,0 +1 @@
+DROP INDEX IF EXISTS lots_status_end_time_idx;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS lots_status_end_time_idx ON lots (status, end_time);
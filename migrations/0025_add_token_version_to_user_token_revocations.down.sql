@@ -0,0 +1,2 @@
+ALTER TABLE user_token_revocations
+    DROP COLUMN IF EXISTS token_version;
@@ -0,0 +1,2 @@
+ALTER TABLE user_token_revocations
+    ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 0;
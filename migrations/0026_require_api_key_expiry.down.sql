@@ -0,0 +1 @@
+ALTER TABLE api_keys ALTER COLUMN expires_at DROP NOT NULL;
@@ -0,0 +1,3 @@
+UPDATE api_keys SET expires_at = created_at + INTERVAL '365 days' WHERE expires_at IS NULL;
+
+ALTER TABLE api_keys ALTER COLUMN expires_at SET NOT NULL;